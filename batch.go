@@ -0,0 +1,333 @@
+package rsqf
+
+// radixSort sorts s in ascending order by the lowest bits of each value using
+// buf as scratch space. Only the passes needed for bits are made. The sorted
+// values are returned in either s or buf.
+func radixSort(s, buf []uint64, bits uint64) []uint64 {
+	for shift := uint64(0); shift < bits; shift += 8 {
+		var counts [256]int
+		for _, v := range s {
+			counts[(v>>shift)&0xFF]++
+		}
+
+		pos := 0
+		for i, c := range counts {
+			counts[i] = pos
+			pos += c
+		}
+
+		for _, v := range s {
+			d := (v >> shift) & 0xFF
+			buf[counts[d]] = v
+			counts[d]++
+		}
+		s, buf = buf, s
+	}
+	return s
+}
+
+// InsertBatch inserts every hash in hashes. The hashes are sorted by
+// fingerprint and merged with the existing entries in place so each run is
+// written once rather than shifted by every insert. Besides a sorted copy of
+// hashes the merge needs a word per block rather than a second filter. Small
+// batches relative to the size of the filter are inserted individually in
+// sorted order. The filter is left unchanged if the batch doesn't fit.
+func (q *Rsqf) InsertBatch(hashes []uint64) error {
+	if q.readOnly {
+		return ErrReadOnly
+	}
+
+	pMask := q.qMask | q.rMask
+	sorted := make([]uint64, len(hashes))
+	for i, x := range hashes {
+		sorted[i] = x & pMask
+	}
+	sorted = radixSort(sorted, make([]uint64, len(sorted)), q.p)
+
+	// a sweep touches every block, it's only worth it for larger batches. A
+	// filter that grows inserts each key so it can grow part way through.
+	if q.growAt > 0 || uint64(len(sorted)) < q.blocks() {
+		for i, x := range sorted {
+			err := q.Insert(x)
+			if err != nil {
+				for _, y := range sorted[:i] {
+					q.Delete(y)
+				}
+				return err
+			}
+		}
+		return nil
+	}
+
+	// the first pass lays out the merged entries without writing them to find
+	// where each block's runs start.
+	starts := make([]uint64, q.blocks())
+	a := appender{q: q, layout: starts}
+	c := cursor{q: q}
+	err := merge(&a, c.next, sorted)
+	if err != nil {
+		return err
+	}
+	err = a.flush()
+	if err != nil {
+		return err
+	}
+
+	// entries only move right so the blocks are rewritten from the last to the
+	// first. Each block's entries are read before its slots are overwritten and
+	// the later blocks have already moved out of the way.
+	q.items, q.used = 0, 0
+	hi := len(sorted)
+	var old []entry
+	for bi := q.blocks(); bi > 0; bi-- {
+		from := (bi - 1) * blockLen
+		lo := hi
+		for lo > 0 && sorted[lo-1]>>q.remainder >= from {
+			lo--
+		}
+		if lo == hi && q.block(bi-1).Occupieds == 0 {
+			continue
+		}
+
+		// the runs of the block start after the run of the last home slot
+		// before it if that run reaches the block.
+		s := from
+		if from > 0 {
+			end, ok := q.lastEnd(from - 1)
+			if ok {
+				s = end + 1
+			}
+		}
+
+		c := cursor{q: q, from: from, s: s}
+		old = old[:0]
+		for x, n, ok := c.nextBefore(from + blockLen); ok; x, n, ok = c.nextBefore(from + blockLen) {
+			old = append(old, entry{x, n})
+		}
+		for ; len(old) > 0 && s <= c.end; s++ {
+			q.Put(s, 0)
+			q.setRunend(s, false)
+		}
+
+		// the layout fits so writing the block can't overflow.
+		a := appender{q: q, next: starts[bi-1]}
+		i := 0
+		err := merge(&a, func() (x, n uint64, ok bool) {
+			if i == len(old) {
+				return 0, 0, false
+			}
+			i++
+			return old[i-1].x, old[i-1].n, true
+		}, sorted[lo:hi])
+		if err != nil {
+			return err
+		}
+		err = a.flush()
+		if err != nil {
+			return err
+		}
+		hi = lo
+	}
+
+	q.fixOffsets(0, q.slots()-1)
+	return nil
+}
+
+// entry is a fingerprint and its count.
+type entry struct {
+	x, n uint64
+}
+
+// merge adds the entries returned by next and the sorted fingerprints ys to a
+// in ascending order.
+func merge(a *appender, next func() (x, n uint64, ok bool), ys []uint64) error {
+	x, n, ok := next()
+	for _, y := range ys {
+		for ok && x <= y {
+			err := a.add(x, n)
+			if err != nil {
+				return err
+			}
+			x, n, ok = next()
+		}
+
+		err := a.add(y, 1)
+		if err != nil {
+			return err
+		}
+	}
+
+	for ; ok; x, n, ok = next() {
+		err := a.add(x, n)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nextBefore is next for entries with a home slot before limit.
+func (c *cursor) nextBefore(limit uint64) (x, n uint64, ok bool) {
+	if !c.inRun || c.s > c.end {
+		_, ok := c.q.nextOccupied(c.from, limit-1)
+		if !ok {
+			return 0, 0, false
+		}
+	}
+	return c.next()
+}
+
+// empty returns a new filter with the same parameters as q.
+func (q *Rsqf) empty() *Rsqf {
+	filter := newRsqf(q.p, q.remainder)
+	filter.counting = q.counting
+	filter.hasher = q.hasher
+	return filter
+}
+
+// appender writes fingerprints in ascending order into a filter whose slots
+// from next onwards are empty. The offsets are calculated once all of the
+// entries have been written.
+type appender struct {
+	q     *Rsqf
+	next  uint64 // first free slot.
+	h0    uint64 // home slot of the last run written.
+	inRun bool
+
+	// layout, when set, receives the first free slot before the first run of
+	// each block and nothing is written to q.
+	layout []uint64
+
+	// the pending entry is held back so counts for the same fingerprint can be
+	// combined in counting mode.
+	x, n uint64
+}
+
+// add appends n instances of the fingerprint x. x must not be less than the
+// previous fingerprint.
+func (a *appender) add(x, n uint64) error {
+	if n == 0 {
+		return nil
+	}
+
+	if a.n > 0 && a.q.counting && x == a.x {
+		a.n += n
+		return nil
+	}
+
+	err := a.flush()
+	if err != nil {
+		return err
+	}
+	a.x, a.n = x, n
+
+	return nil
+}
+
+// flush writes the pending entry.
+func (a *appender) flush() error {
+	if a.n == 0 {
+		return nil
+	}
+
+	q := a.q
+	h0, h1 := q.split(a.x)
+
+	var slots []uint64
+	if q.counting {
+		slots = q.encodeCounter(h1, a.n)
+	} else {
+		slots = make([]uint64, a.n)
+		for i := range slots {
+			slots[i] = h1
+		}
+	}
+
+	s := a.next
+	run := a.inRun && h0 == a.h0
+	if !run {
+		if a.layout != nil && (!a.inRun || h0/blockLen != a.h0/blockLen) {
+			a.layout[h0/blockLen] = s
+		}
+		if s < h0 {
+			s = h0
+		}
+	}
+
+	if s+uint64(len(slots)) > q.slots() {
+		return ErrFilterOverflow
+	}
+
+	a.next = s + uint64(len(slots))
+	a.h0, a.inRun = h0, true
+	if a.layout != nil {
+		a.n = 0
+		return nil
+	}
+
+	if run {
+		q.setRunend(s-1, false)
+	}
+	for i, v := range slots {
+		q.Put(s+uint64(i), v)
+	}
+	q.setRunend(a.next-1, true)
+
+	var o uint64 = (0x01 << (h0 % blockLen))
+	q.block(h0 / blockLen).Occupieds |= o
+
+	q.items += a.n
+	q.used += uint64(len(slots))
+	a.n = 0
+
+	return nil
+}
+
+// finish writes the pending entry and calculates the offsets of every block.
+func (a *appender) finish() error {
+	err := a.flush()
+	if err != nil {
+		return err
+	}
+
+	a.q.fixOffsets(0, a.q.slots()-1)
+	return nil
+}
+
+// lookahead is the number of lookups in MayContainBatch whose blocks are loaded
+// before any of them are resolved.
+const lookahead = 16
+
+// MayContainBatch sets out[i] to the result of MayContainHash(hashes[i]). out
+// must be at least as long as hashes. The blocks for a group of lookups are
+// touched before the group is resolved so their memory loads overlap rather
+// than waiting on one cache miss at a time. Lookups with an unoccupied home
+// slot are answered by the first pass.
+func (q *Rsqf) MayContainBatch(hashes []uint64, out []bool) {
+	_ = out[:len(hashes)]
+
+	// the larger filter holds entries whose home slots aren't occupied in q.
+	if q.grow != nil {
+		for i, x := range hashes {
+			out[i] = q.MayContainHash(x)
+		}
+		return
+	}
+
+	var occupied [lookahead]bool
+	for i := 0; i < len(hashes); i += lookahead {
+		group := hashes[i:]
+		if len(group) > lookahead {
+			group = group[:lookahead]
+		}
+
+		for j, x := range group {
+			h0, _ := q.split(x)
+			occupied[j] = h0 < q.slots() && q.isOccupied(h0)
+		}
+
+		for j, x := range group {
+			out[i+j] = occupied[j] && q.MayContainHash(x)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package rsqf_test
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	. "github.com/nfisher/rsqf"
+)
+
+func Test_InsertBatch_sweep_should_match_individual_inserts(t *testing.T) {
+	t.Parallel()
+	// 21 blocks so batches of 21 or more keys use the sweep. Runs are
+	// displaced by the keys before them and fill the overflow slots.
+	batch := []uint64{0x3FF<<9 | 0x02, 0x0, 0x1FF, 0x0, 0x2 << 9, 0x1 << 9}
+	for i := uint64(0); i < 40; i++ {
+		batch = append(batch, 0x3FF<<9|i%3, 0x3FE<<9|i)
+	}
+
+	f := New(1000)
+	err := f.InsertBatch(batch)
+	if err != nil {
+		t.Fatalf("want InsertBatch() err = nil, got %v", err)
+	}
+
+	g := New(1000)
+	for _, x := range batch {
+		g.Insert(x)
+	}
+
+	a, _ := f.MarshalBinary()
+	b, _ := g.MarshalBinary()
+	if !bytes.Equal(a, b) {
+		t.Error("want InsertBatch() to match Insert() of each key")
+	}
+
+	err = f.Verify()
+	if err != nil {
+		t.Errorf("want Verify() = nil, got %v", err)
+	}
+}
+
+func Test_InsertBatch_should_add_to_existing_counters(t *testing.T) {
+	t.Parallel()
+	f := NewCounting(1000)
+	f.InsertN(0x05<<9|0x07, 3)
+	f.InsertN(0x05<<9|0x09, 1)
+
+	// duplicates within the batch and of existing entries are combined.
+	batch := make([]uint64, 30)
+	for i := range batch {
+		batch[i] = uint64(i+6) << 9
+	}
+	batch = append(batch, 0x05<<9|0x07, 0x05<<9|0x08, 0x05<<9|0x07, 0x05<<9|0x08)
+
+	err := f.InsertBatch(batch)
+	if err != nil {
+		t.Fatalf("want InsertBatch() err = nil, got %v", err)
+	}
+
+	td := [][]uint64{
+		// hash, count
+		{0x05<<9 | 0x07, 5},
+		{0x05<<9 | 0x08, 2},
+		{0x05<<9 | 0x09, 1},
+		{0x06 << 9, 1},
+	}
+	for _, v := range td {
+		if f.Count(v[0]) != v[1] {
+			t.Errorf("want Count(0x%X) = %v, got %v", v[0], v[1], f.Count(v[0]))
+		}
+	}
+
+	if f.Items() != 38 {
+		t.Errorf("want Items() = 38, got %v", f.Items())
+	}
+
+	err = f.Verify()
+	if err != nil {
+		t.Errorf("want Verify() = nil, got %v", err)
+	}
+}
+
+func Test_InsertBatch_overflow_should_leave_filter_unchanged(t *testing.T) {
+	t.Parallel()
+	// 1024 home slots, batches of 16 or more keys use the sweep.
+	td := []int{2, 32}
+
+	for _, size := range td {
+		f := New(1000)
+		f.Insert(0x1FFF)
+		// the last home slot and the 320 overflow slots after it are full.
+		for i := 0; i < 321; i++ {
+			f.Insert(0x3FF << 9)
+		}
+		before, _ := f.MarshalBinary()
+
+		batch := make([]uint64, size)
+		for i := range batch {
+			batch[i] = uint64(i) << 9
+		}
+		batch[size-1] = 0x3FF<<9 | 0x01
+
+		err := f.InsertBatch(batch)
+		if err != ErrFilterOverflow {
+			t.Errorf("[%v] want InsertBatch() err = ErrFilterOverflow, got %v", size, err)
+		}
+
+		after, _ := f.MarshalBinary()
+		if !bytes.Equal(before, after) {
+			t.Errorf("[%v] want filter to be unchanged after ErrFilterOverflow", size)
+		}
+	}
+}
+
+func Test_MayContainBatch_should_match_MayContainHash(t *testing.T) {
+	t.Parallel()
+	// the growing filter starts migrating at 512 slots so the batch is looked
+	// up part way through.
+	growing, _ := NewWithConfig(Config{N: 1000, GrowAt: 0.5})
+	td := []*Rsqf{New(1000), growing}
+
+	inserted := randomKeys(4, 530)
+	// an uneven length leaves a partial group.
+	hashes := append(append([]uint64(nil), inserted...), randomKeys(5, 469)...)
+
+	for i, f := range td {
+		for _, x := range inserted {
+			f.Insert(x)
+		}
+
+		out := make([]bool, len(hashes))
+		f.MayContainBatch(hashes, out)
+
+		for j, x := range hashes {
+			if f.MayContainHash(x) != out[j] {
+				t.Fatalf("[%v] want out[%v] = %v, got %v", i, j, f.MayContainHash(x), out[j])
+			}
+			if j < len(inserted) && !out[j] {
+				t.Fatalf("[%v] want out[%v] = true for an inserted key, got false", i, j)
+			}
+		}
+	}
+}
+
+func Test_InsertBatch_should_move_existing_entries_right(t *testing.T) {
+	t.Parallel()
+	td := []bool{false, true}
+
+	for _, counting := range td {
+		f, g := New(1000), New(1000)
+		if counting {
+			f, g = NewCounting(1000), NewCounting(1000)
+		}
+
+		// existing clusters are displaced by the batch keys before them and
+		// spread across blocks and into the overflow slots.
+		var existing []uint64
+		for i := uint64(0); i < 30; i++ {
+			existing = append(existing, 0x40<<9|i, 0x3FF<<9|i%4, 0x1<<9|i%2)
+		}
+		for _, x := range existing {
+			f.Insert(x)
+			g.Insert(x)
+		}
+
+		var batch []uint64
+		for i := uint64(0); i < 40; i++ {
+			batch = append(batch, 0x3F<<9|i, 0x3FE<<9|i%5, 0x1<<9|i%2, i<<9)
+		}
+
+		err := f.InsertBatch(batch)
+		if err != nil {
+			t.Fatalf("[%v] want InsertBatch() err = nil, got %v", counting, err)
+		}
+		for _, x := range batch {
+			g.Insert(x)
+		}
+
+		a, _ := f.MarshalBinary()
+		b, _ := g.MarshalBinary()
+		if !bytes.Equal(a, b) {
+			t.Errorf("[%v] want InsertBatch() to match Insert() of each key", counting)
+		}
+
+		err = f.Verify()
+		if err != nil {
+			t.Errorf("[%v] want Verify() = nil, got %v", counting, err)
+		}
+	}
+}
+
+func Test_InsertBatch_should_match_individual_inserts_into_filled_filters(t *testing.T) {
+	t.Parallel()
+	r := rand.New(rand.NewSource(11))
+
+	for i := 0; i < 20; i++ {
+		counting := i%2 == 1
+		f, g := New(1000), New(1000)
+		if counting {
+			f, g = NewCounting(1000), NewCounting(1000)
+		}
+
+		// half of the keys share the first 32 home slots so fingerprints repeat
+		// and their cluster spans several blocks.
+		key := func(j int) uint64 {
+			if j%2 == 0 {
+				return r.Uint64() % (1 << 14)
+			}
+			return r.Uint64()
+		}
+
+		for j := 0; j < 300; j++ {
+			x := key(j)
+			f.Insert(x)
+			g.Insert(x)
+		}
+
+		batch := make([]uint64, 400)
+		for j := range batch {
+			batch[j] = key(j)
+		}
+
+		err := f.InsertBatch(batch)
+		if err != nil {
+			t.Fatalf("[%v] want InsertBatch() err = nil, got %v", i, err)
+		}
+		for _, x := range batch {
+			g.Insert(x)
+		}
+
+		a, _ := f.MarshalBinary()
+		b, _ := g.MarshalBinary()
+		if !bytes.Equal(a, b) {
+			t.Errorf("[%v] want InsertBatch() to match Insert() of each key", i)
+		}
+	}
+}
@@ -0,0 +1,356 @@
+package rsqf
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// regionBlocks is the number of blocks protected by each region lock.
+const regionBlocks = 64
+
+// errEscalate is returned by an operation on a view that needs more of the
+// filter than the regions that were locked.
+var errEscalate = errors.New("RSQF operation crosses the locked regions")
+
+// readWindow is the number of blocks, starting with the block of the home
+// slot, copied by a lookup that doesn't take any locks.
+const readWindow = 4
+
+// region is a lock over regionBlocks blocks. seq is incremented before and
+// after a writer modifies the region so it is odd while the blocks change.
+type region struct {
+	seq uint64 // updated atomically, first so it's 64-bit aligned.
+	mu  sync.RWMutex
+}
+
+// ConcurrentRsqf is a filter that is safe for use by multiple goroutines. Q is
+// partitioned into regions of 64 blocks with their own locks. An operation
+// locks the region of its home slot and both neighbours so that clusters can
+// spill across region boundaries. The rare operation whose cluster extends
+// past its neighbours locks every region in the same order.
+//
+// Lookups don't take locks. They copy the blocks around the home slot and
+// use the copy if the sequence numbers of its regions didn't change while it
+// was taken, otherwise they wait on shared locks.
+type ConcurrentRsqf struct {
+	items   uint64 // updated atomically, first so it's 64-bit aligned.
+	f       *Rsqf
+	regions []region
+}
+
+// NewConcurrent returns a ConcurrentRsqf that takes ownership of f. f must not
+// be used directly afterwards. A filter created with GrowAt completes any
+// migration and stops growing, regions are fixed once they're allocated.
+func NewConcurrent(f *Rsqf) *ConcurrentRsqf {
+	f.finishGrow()
+	f.growAt = 0
+
+	n := (int(f.blocks()) + regionBlocks - 1) / regionBlocks
+	return &ConcurrentRsqf{
+		items:   f.items,
+		f:       f,
+		regions: make([]region, n, n),
+	}
+}
+
+// Items returns the number of instances stored in the filter.
+func (c *ConcurrentRsqf) Items() uint64 {
+	return atomic.LoadUint64(&c.items)
+}
+
+// Verify checks the invariants of the filter while holding every lock. See
+// Rsqf.Verify.
+func (c *ConcurrentRsqf) Verify() error {
+	last := len(c.regions) - 1
+	c.lock(0, last, false)
+	defer c.unlock(0, last, false)
+
+	v := c.view(0, last)
+	v.items = c.Items()
+	return v.Verify()
+}
+
+// Hash applies the filter's hashing algorithm to b.
+func (c *ConcurrentRsqf) Hash(b []byte) uint64 {
+	return c.f.Hash(b)
+}
+
+// view returns a filter that shares the blocks of regions [first, last].
+func (c *ConcurrentRsqf) view(first, last int) *Rsqf {
+	from := uint64(first) * regionBlocks
+	to := uint64(last+1) * regionBlocks
+	if to > c.f.blocks() {
+		to = c.f.blocks()
+	}
+
+	stride := blockMeta + c.f.remainder
+	v := *c.f
+	v.Q = c.f.Q[from*stride : to*stride]
+	v.items = 0
+	return &v
+}
+
+// lock locks regions [first, last] in ascending order, exclusively if write
+// is true. Every caller locks in the same order so they can't deadlock.
+func (c *ConcurrentRsqf) lock(first, last int, write bool) {
+	for i := first; i <= last; i++ {
+		r := &c.regions[i]
+		if write {
+			r.mu.Lock()
+			atomic.AddUint64(&r.seq, 1)
+		} else {
+			r.mu.RLock()
+		}
+	}
+}
+
+// unlock releases the regions locked by lock.
+func (c *ConcurrentRsqf) unlock(first, last int, write bool) {
+	for i := last; i >= first; i-- {
+		r := &c.regions[i]
+		if write {
+			atomic.AddUint64(&r.seq, 1)
+			r.mu.Unlock()
+		} else {
+			r.mu.RUnlock()
+		}
+	}
+}
+
+// do runs fn with the hash x rebased onto a view of the locked regions around
+// its home slot. fn returns errEscalate if it needs the rest of the filter in
+// which case it is run again with every region locked.
+func (c *ConcurrentRsqf) do(x uint64, write bool, fn func(v *Rsqf, x uint64) error) error {
+	h0, h1 := c.f.split(x)
+	k := int(h0 / (regionBlocks * blockLen))
+
+	first, last := k, k+1
+	if first > 0 {
+		first--
+	}
+	if last >= len(c.regions) {
+		last = len(c.regions) - 1
+	}
+
+	c.lock(first, last, write)
+	v := c.view(first, last)
+	base := uint64(first) * regionBlocks * blockLen
+	err := errEscalate
+	// a saturated offset in the first block needs the blocks before the view.
+	if first == 0 || v.block(0).Offset != offsetMax {
+		err = fn(v, (h0-base)<<v.remainder|h1)
+	}
+	atomic.AddUint64(&c.items, v.items)
+	c.unlock(first, last, write)
+
+	if err != errEscalate {
+		return err
+	}
+
+	last = len(c.regions) - 1
+	c.lock(0, last, write)
+	defer c.unlock(0, last, write)
+
+	v = c.view(0, last)
+	err = fn(v, x)
+	atomic.AddUint64(&c.items, v.items)
+	if err == errEscalate {
+		return ErrFilterOverflow
+	}
+	return err
+}
+
+// window holds the blocks copied by read. It has room for the largest
+// remainder.
+type window [readWindow * (blockMeta + 64 - blockBits)]uint64
+
+// read returns the count, or 1 if count is false and the filter may contain
+// x, from a copy of the blocks around the home slot of x without taking any
+// locks. ok is false if the copy can't be used because a writer modified its
+// regions or the run for x doesn't end in it.
+func (c *ConcurrentRsqf) read(x uint64, count bool) (n uint64, ok bool) {
+	q := c.f
+	h0, h1 := q.split(x)
+	from := h0 / blockLen
+	to := from + readWindow
+	if to > q.blocks() {
+		to = q.blocks()
+	}
+
+	// the window is smaller than a region so it covers at most two.
+	first, last := from/regionBlocks, (to-1)/regionBlocks
+	var seqs [2]uint64
+	for i := first; i <= last; i++ {
+		seqs[i-first] = atomic.LoadUint64(&c.regions[i].seq)
+		if seqs[i-first]&0x1 == 1 {
+			return 0, false
+		}
+	}
+
+	var w window
+	r := q.remainder
+	stride := blockMeta + r
+	copyWindow(w[:(to-from)*stride], q.Q[from*stride:to*stride])
+
+	// the atomic store orders the copy before the sequence numbers are read
+	// again.
+	var fence uint32
+	atomic.StoreUint32(&fence, 1)
+	for i := first; i <= last; i++ {
+		if atomic.LoadUint64(&c.regions[i].seq) != seqs[i-first] {
+			return 0, false
+		}
+	}
+
+	v := *q
+	v.Q = w[:(to-from)*stride]
+	x = (h0-from*blockLen)<<r | h1
+	// a saturated offset needs the blocks before the copy.
+	if (from > 0 && v.block(0).Offset == offsetMax) || !v.runInView(x) {
+		return 0, false
+	}
+
+	if count {
+		return v.Count(x), true
+	}
+	if v.MayContainHash(x) {
+		return 1, true
+	}
+	return 0, true
+}
+
+// copyWindow copies blocks that writers may be modifying. read discards the
+// copy if they were so it isn't checked by the race detector.
+//
+//go:norace
+func copyWindow(dst, src []uint64) {
+	for i := range dst {
+		dst[i] = src[i]
+	}
+}
+
+// runInView returns true if the run for the hash x ends inside the view v.
+func (v *Rsqf) runInView(x uint64) bool {
+	h0, _ := v.split(x)
+	end, _ := v.lastEnd(h0)
+	return end < v.slots()
+}
+
+// escalate converts an overflow on a view into errEscalate.
+func escalate(err error) error {
+	if err == ErrFilterOverflow {
+		return errEscalate
+	}
+	return err
+}
+
+// Insert places the hash x into the filter.
+func (c *ConcurrentRsqf) Insert(x uint64) error {
+	return c.InsertN(x, 1)
+}
+
+// InsertN adds n instances of the hash x to the filter.
+func (c *ConcurrentRsqf) InsertN(x, n uint64) error {
+	return c.f.perInstance(x, n, c.insert)
+}
+
+// insert is InsertN for a single instance or counter.
+func (c *ConcurrentRsqf) insert(x, n uint64) error {
+	return c.do(x, true, func(v *Rsqf, x uint64) error {
+		if !v.runInView(x) {
+			return errEscalate
+		}
+		return escalate(v.InsertN(x, n))
+	})
+}
+
+// InsertBytes hashes b and inserts the result into the filter.
+func (c *ConcurrentRsqf) InsertBytes(b []byte) error {
+	return c.Insert(c.Hash(b))
+}
+
+// InsertString hashes s and inserts the result into the filter.
+func (c *ConcurrentRsqf) InsertString(s string) error {
+	return c.InsertBytes([]byte(s))
+}
+
+// Delete removes one instance of the hash x from the filter. It returns false
+// if x was not found.
+func (c *ConcurrentRsqf) Delete(x uint64) (bool, error) {
+	return c.DeleteN(x, 1)
+}
+
+// DeleteN removes up to n instances of the hash x from the filter. It returns
+// false if nothing was removed.
+func (c *ConcurrentRsqf) DeleteN(x, n uint64) (bool, error) {
+	var found bool
+	if !c.f.counting && n > 1 {
+		for ; n > 0; n-- {
+			ok, err := c.DeleteN(x, 1)
+			if err != nil || !ok {
+				return found, err
+			}
+			found = true
+		}
+		return found, nil
+	}
+
+	err := c.do(x, true, func(v *Rsqf, x uint64) error {
+		if !v.runInView(x) {
+			return errEscalate
+		}
+
+		var err error
+		found, err = v.DeleteN(x, n)
+		return escalate(err)
+	})
+	return found, err
+}
+
+// Count returns the number of instances of the hash x in the filter.
+func (c *ConcurrentRsqf) Count(x uint64) uint64 {
+	n, ok := c.read(x, true)
+	if ok {
+		return n
+	}
+
+	c.do(x, false, func(v *Rsqf, x uint64) error {
+		if !v.runInView(x) {
+			return errEscalate
+		}
+
+		n = v.Count(x)
+		return nil
+	})
+	return n
+}
+
+// MayContainHash tests if the hash x exists in this filter.
+func (c *ConcurrentRsqf) MayContainHash(x uint64) bool {
+	n, ok := c.read(x, false)
+	if ok {
+		return n > 0
+	}
+
+	var found bool
+	c.do(x, false, func(v *Rsqf, x uint64) error {
+		if !v.runInView(x) {
+			return errEscalate
+		}
+
+		found = v.MayContainHash(x)
+		return nil
+	})
+	return found
+}
+
+// MayContain tests if b exists in this filter.
+func (c *ConcurrentRsqf) MayContain(b []byte) bool {
+	return c.MayContainHash(c.Hash(b))
+}
+
+// MayContainString tests if s exists in this filter.
+func (c *ConcurrentRsqf) MayContainString(s string) bool {
+	return c.MayContain([]byte(s))
+}
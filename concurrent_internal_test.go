@@ -0,0 +1,57 @@
+package rsqf
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_ConcurrentRsqf_lookups_should_not_take_locks(t *testing.T) {
+	t.Parallel()
+	c := NewConcurrent(New(1 << 16))
+	x := uint64(4096<<9 | 0x05)
+	c.Insert(x)
+
+	// a writer of region 1 that hasn't started modifying it.
+	c.regions[1].mu.Lock()
+	defer c.regions[1].mu.Unlock()
+
+	found := make(chan bool)
+	go func() {
+		found <- c.MayContainHash(x) && c.Count(x) == 1
+	}()
+
+	select {
+	case ok := <-found:
+		if !ok {
+			t.Error("want MayContainHash() = true and Count() = 1, got false")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("want lookup to complete without the region lock")
+	}
+}
+
+func Test_ConcurrentRsqf_read_should_not_use_a_region_being_modified(t *testing.T) {
+	t.Parallel()
+	c := NewConcurrent(New(1 << 16))
+	td := []uint64{
+		4096<<9 | 0x05,
+		// the window of the home slot covers regions 0 and 1.
+		(4096-blockLen)<<9 | 0x05,
+	}
+
+	for _, x := range td {
+		c.Insert(x)
+
+		c.regions[1].seq++
+		_, ok := c.read(x, false)
+		if ok {
+			t.Errorf("want read(0x%X) ok = false while region 1 is modified, got true", x)
+		}
+
+		c.regions[1].seq++
+		n, ok := c.read(x, false)
+		if !ok || n != 1 {
+			t.Errorf("want read(0x%X) = 1, true, got %v, %v", x, n, ok)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package rsqf_test
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+
+	. "github.com/nfisher/rsqf"
+)
+
+func Test_ConcurrentRsqf_stress(t *testing.T) {
+	t.Parallel()
+	// p = 25, r = 9 and q = 16 so each region of 4096 slots has 256 keys.
+	c := NewConcurrent(New(1 << 16))
+
+	const workers = 8
+	const perWorker = 2000
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+
+	keys := make([][]uint64, workers)
+	for w := range keys {
+		r := rand.New(rand.NewSource(int64(w)))
+		keys[w] = make([]uint64, perWorker)
+		for i := range keys[w] {
+			keys[w][i] = r.Uint64()
+		}
+	}
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(keys []uint64) {
+			defer wg.Done()
+			for _, x := range keys {
+				err := c.Insert(x)
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+
+			for _, x := range keys {
+				if !c.MayContainHash(x) {
+					t.Errorf("want MayContainHash(0x%X) = true, got false", x)
+					return
+				}
+			}
+
+			for _, x := range keys[:len(keys)/2] {
+				ok, err := c.Delete(x)
+				if !ok || err != nil {
+					t.Errorf("want Delete(0x%X) = true, nil, got %v, %v", x, ok, err)
+					return
+				}
+			}
+		}(keys[w])
+	}
+
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("want err = nil, got %v", err)
+	}
+
+	if expected := uint64(workers * perWorker / 2); expected != c.Items() {
+		t.Errorf("want Items() = %v, got %v", expected, c.Items())
+	}
+
+	for w := range keys {
+		for _, x := range keys[w][perWorker/2:] {
+			if !c.MayContainHash(x) {
+				t.Fatalf("want MayContainHash(0x%X) = true, got false", x)
+			}
+		}
+	}
+
+	err := c.Verify()
+	if err != nil {
+		t.Errorf("want Verify() = nil, got %v", err)
+	}
+}
+
+func Test_ConcurrentRsqf_should_lock_every_region_for_a_cluster_past_its_neighbours(t *testing.T) {
+	t.Parallel()
+	// regions hold 4096 slots, an insert at the end of region 1 locks regions
+	// 0 to 2 so a cluster longer than 4096 slots needs every region.
+	c := NewConcurrent(New(1 << 16))
+	x := uint64((2*4096-1)<<9 | 0x05)
+
+	// runs in region 3 are displaced by the cluster while they're read.
+	var displaced []uint64
+	for h0 := uint64(3 * 4096); h0 < 3*4096+200; h0 += 7 {
+		displaced = append(displaced, h0<<9|0x11)
+		c.Insert(h0<<9 | 0x11)
+	}
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+
+			for _, y := range displaced {
+				if !c.MayContainHash(y) {
+					t.Errorf("want MayContainHash(0x%X) = true, got false", y)
+					return
+				}
+			}
+		}
+	}()
+
+	err := c.InsertN(x, 4200)
+	close(done)
+	wg.Wait()
+	if err != nil {
+		t.Fatalf("want InsertN() err = nil, got %v", err)
+	}
+
+	if c.Count(x) != 4200 {
+		t.Errorf("want Count(0x%X) = 4200, got %v", x, c.Count(x))
+	}
+
+	ok, err := c.DeleteN(x, 4200)
+	if !ok || err != nil {
+		t.Errorf("want DeleteN() = true, nil, got %v, %v", ok, err)
+	}
+
+	if c.Items() != uint64(len(displaced)) {
+		t.Errorf("want Items() = %v, got %v", len(displaced), c.Items())
+	}
+
+	err = c.Verify()
+	if err != nil {
+		t.Errorf("want Verify() = nil, got %v", err)
+	}
+}
@@ -0,0 +1,31 @@
+package rsqf
+
+// cpuid executes the CPUID instruction for leaf eaxArg and sub-leaf ecxArg.
+func cpuid(eaxArg, ecxArg uint32) (eax, ebx, ecx, edx uint32)
+
+// hasFastPDEP is true if the CPU supports BMI2 with a fast PDEP. AMD CPUs
+// before Zen 3 implement PDEP in microcode where it is slower than the
+// broadword select.
+var hasFastPDEP = func() bool {
+	maxLeaf, b, c, d := cpuid(0, 0)
+	if maxLeaf < 7 {
+		return false
+	}
+
+	_, ebx, _, _ := cpuid(7, 0)
+	if ebx&(1<<8) == 0 {
+		return false
+	}
+
+	// vendor is "AuthenticAMD" in ebx, edx, ecx.
+	if b == 0x68747541 && d == 0x69746e65 && c == 0x444d4163 {
+		eax, _, _, _ := cpuid(1, 0)
+		family := (eax >> 8) & 0xF
+		if family == 0xF {
+			family += (eax >> 20) & 0xFF
+		}
+		return family >= 0x19
+	}
+
+	return true
+}()
@@ -0,0 +1,323 @@
+package rsqf
+
+// NewCounting returns a new counting quotient filter (CQF). Repeated
+// remainders within a run are stored as variable-length counters rather than
+// one slot per instance.
+func NewCounting(n float64) *Rsqf {
+	filter := New(n)
+	filter.counting = true
+	return filter
+}
+
+// InsertN adds n instances of the hash x to the filter.
+func (q *Rsqf) InsertN(x, n uint64) error {
+	if q.readOnly {
+		return ErrReadOnly
+	}
+
+	if n == 0 {
+		return nil
+	}
+
+	if q.growAt > 0 {
+		return q.growInsertN(x, n)
+	}
+
+	if !q.counting {
+		for ; n > 0; n-- {
+			err := q.Insert(x)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	h0, h1 := q.split(x)
+	_, err := q.setCount(h0, h1, func(c uint64) uint64 {
+		return c + n
+	})
+	if err != nil {
+		return err
+	}
+	q.items += n
+
+	return nil
+}
+
+// perInstance calls insert once for each of the n instances of x when q isn't
+// counting. Each instance takes a slot so an insert that is retried after an
+// overflow doesn't repeat the instances that fit. A counting filter stores
+// the instances in one counter so insert is called once.
+func (q *Rsqf) perInstance(x, n uint64, insert func(x, n uint64) error) error {
+	if q.counting || n < 2 {
+		return insert(x, n)
+	}
+
+	for ; n > 0; n-- {
+		err := insert(x, 1)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteN removes up to n instances of the hash x from the filter. It returns
+// false if nothing was removed.
+func (q *Rsqf) DeleteN(x, n uint64) (bool, error) {
+	if q.readOnly {
+		return false, ErrReadOnly
+	}
+
+	if n == 0 {
+		return false, nil
+	}
+
+	if q.growAt > 0 {
+		return q.growDeleteN(x, n)
+	}
+
+	if !q.counting {
+		var found bool
+		for ; n > 0; n-- {
+			ok, err := q.Delete(x)
+			if err != nil || !ok {
+				return found, err
+			}
+			found = true
+		}
+		return found, nil
+	}
+
+	h0, h1 := q.split(x)
+	if !q.isOccupied(h0) {
+		return false, nil
+	}
+
+	c, err := q.setCount(h0, h1, func(c uint64) uint64 {
+		if c < n {
+			return 0
+		}
+		return c - n
+	})
+	if err != nil {
+		return false, err
+	}
+
+	if c < n {
+		n = c
+	}
+	q.items -= n
+
+	return c > 0, nil
+}
+
+// Count returns the number of instances of the hash x in the filter.
+func (q *Rsqf) Count(x uint64) uint64 {
+	var c uint64
+	if g := q.grow; g != nil {
+		c = g.next.Count(x)
+		if x&(q.qMask|q.rMask) < g.boundary {
+			return c
+		}
+	}
+
+	h0, h1 := q.split(x)
+
+	if !q.isOccupied(h0) {
+		return c
+	}
+
+	// a missing runend leaves the run without an end in the filter.
+	end, ok := q.lastEnd(h0)
+	if !ok || end >= q.slots() {
+		return c
+	}
+	s := q.runStart(h0, end)
+
+	for s <= end {
+		r, n, next := q.decodeEntry(s, end)
+		if r > h1 {
+			break
+		}
+		if r == h1 {
+			c += n
+		}
+		s = next
+	}
+	return c
+}
+
+// setCount changes the count of remainder h1 in the run for home slot h0 to
+// the value returned by update. A count of 0 removes the remainder. It returns
+// the previous count.
+func (q *Rsqf) setCount(h0, h1 uint64, update func(uint64) uint64) (uint64, error) {
+	// at is the first slot of h1's entry or where it should be added.
+	at := h0
+	var c, size uint64
+
+	end, ok := q.lastEnd(h0)
+	if q.isOccupied(h0) {
+		if !ok || end >= q.slots() {
+			return 0, ErrCorrupt
+		}
+		at = q.runStart(h0, end)
+		for at <= end {
+			r, n, next := q.decodeEntry(at, end)
+			if r > h1 {
+				break
+			}
+			if r == h1 {
+				c, size = n, next-at
+				break
+			}
+			at = next
+		}
+	} else if ok {
+		at = end + 1
+	}
+
+	return c, q.splice(h0, at, size, q.encodeCounter(h1, update(c)))
+}
+
+// splice replaces size slots starting at slot at in the run for home slot h0
+// with slots. The filter is left unchanged if there isn't enough space.
+func (q *Rsqf) splice(h0, at, size uint64, slots []uint64) error {
+	n := uint64(len(slots))
+
+	for i := size; i < n; i++ {
+		err := q.insertSlot(h0, at+i)
+		if err != nil {
+			for ; i > size; i-- {
+				q.removeSlot(h0, at+i-1)
+			}
+			return err
+		}
+	}
+
+	for i := n; i < size; i++ {
+		err := q.removeSlot(h0, at+n)
+		if err != nil {
+			return err
+		}
+	}
+
+	for i, v := range slots {
+		q.Put(at+uint64(i), v)
+	}
+	return nil
+}
+
+/*
+encodeCounter returns the slots used to store c instances of remainder x. The
+encoding follows the CQF paper, counters are digits that break the ascending
+order of the remainders in a run so they can be told apart;
+
+	c = 1:         x
+	c = 2:         x x
+	c > 2, x > 0:  x [0] digits(c - 3) x
+	c > 2, x = 0:  0 digits(c - 3) 0 0
+
+For x > 0 the digits are base 2^r - 2 using the values 1 to 2^r - 1 except x. A
+0 is prepended when the most significant digit is larger than x so the slot
+following x is always smaller than x. For x = 0 the digits are base 2^r - 1
+using the values 1 to 2^r - 1.
+*/
+func (q *Rsqf) encodeCounter(x, c uint64) []uint64 {
+	switch c {
+	case 0:
+		return nil
+	case 1:
+		return []uint64{x}
+	case 2:
+		return []uint64{x, x}
+	}
+
+	base := q.rMask - 1
+	if x == 0 {
+		base = q.rMask
+	}
+
+	// digits are least significant first.
+	var digits []uint64
+	for v := c - 3; ; v /= base {
+		d := v%base + 1
+		if x > 0 && d >= x {
+			d++
+		}
+		digits = append(digits, d)
+		if v < base {
+			break
+		}
+	}
+
+	slots := []uint64{x}
+	if x > 0 && digits[len(digits)-1] > x {
+		slots = append(slots, 0)
+	}
+	for i := len(digits) - 1; i >= 0; i-- {
+		slots = append(slots, digits[i])
+	}
+
+	if x == 0 {
+		return append(slots, 0, 0)
+	}
+	return append(slots, x)
+}
+
+// decodeEntry reads the remainder starting in slot s of a run ending in slot
+// end. It returns the remainder, its count and the slot after the entry.
+func (q *Rsqf) decodeEntry(s, end uint64) (uint64, uint64, uint64) {
+	x := q.Get(s)
+	if !q.counting || s == end {
+		return x, 1, s + 1
+	}
+
+	y := q.Get(s + 1)
+	if y == x {
+		return x, 2, s + 2
+	}
+
+	if x == 0 {
+		// only the counter for 0 contains two consecutive 0s.
+		for t := s + 1; t < end; t++ {
+			if q.Get(t) == 0 && q.Get(t+1) == 0 {
+				return x, 3 + q.decodeDigits(x, s+1, t), t + 2
+			}
+		}
+		return x, 1, s + 1
+	}
+
+	if y > x {
+		return x, 1, s + 1
+	}
+
+	t := s + 1
+	for t < end && q.Get(t) != x {
+		t++
+	}
+	return x, 3 + q.decodeDigits(x, s+1, t), t + 1
+}
+
+// decodeDigits returns the value of the counter for x stored in slots
+// [from, to).
+func (q *Rsqf) decodeDigits(x, from, to uint64) uint64 {
+	base := q.rMask - 1
+	if x == 0 {
+		base = q.rMask
+	}
+
+	var v uint64
+	for s := from; s < to; s++ {
+		d := q.Get(s)
+		if d == 0 {
+			continue
+		}
+		if x > 0 && d > x {
+			d--
+		}
+		v = v*base + d - 1
+	}
+	return v
+}
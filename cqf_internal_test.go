@@ -0,0 +1,142 @@
+package rsqf
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func Test_encodeCounter_should_roundtrip(t *testing.T) {
+	t.Parallel()
+	f := NewCounting(100000)
+	counts := []uint64{1, 2, 3, 4, 5, 509, 510, 511, 512, 260000, 1 << 63}
+	remainders := []uint64{0x000, 0x001, 0x002, 0x0FF, 0x1FE, 0x1FF}
+
+	for _, x := range remainders {
+		for _, c := range counts {
+			slots := f.encodeCounter(x, c)
+			if uint64(len(slots)) > f.slots() {
+				t.Fatalf("encodeCounter(0x%X, %v) too long", x, c)
+			}
+
+			// follow with a larger remainder to ensure the boundary is found.
+			end := uint64(len(slots))
+			for i, v := range slots {
+				f.Put(uint64(i), v)
+			}
+			f.Put(end, 0x1FF)
+			if x == 0x1FF {
+				end--
+			}
+
+			r, n, next := f.decodeEntry(0, end)
+			if x != r || c != n || uint64(len(slots)) != next {
+				t.Errorf("want decodeEntry(encodeCounter(0x%X, %v)) = 0x%X, %v, %v, got 0x%X, %v, %v",
+					x, c, x, c, len(slots), r, n, next)
+			}
+		}
+	}
+}
+
+func Test_counting_should_match_model(t *testing.T) {
+	t.Parallel()
+	f := NewCounting(10000)
+	r := rand.New(rand.NewSource(5))
+	pmask := f.qMask | f.rMask
+	model := map[uint64]uint64{}
+
+	for i := 0; i < 30000; i++ {
+		// few home slots and remainders so counters share runs.
+		x := uint64(r.Intn(600)*27)<<9 | uint64(r.Intn(4))
+		switch r.Intn(4) {
+		case 0:
+			n := uint64(r.Intn(3))
+			ok, err := f.DeleteN(x, n)
+			if err != nil {
+				t.Fatalf("[%v] want DeleteN(0x%X, %v) err = nil, got %v", i, x, n, err)
+			}
+			if ok != (model[x&pmask] > 0 && n > 0) {
+				t.Fatalf("[%v] want DeleteN(0x%X, %v) = %v, got %v", i, x, n, !ok, ok)
+			}
+			if model[x&pmask] < n {
+				model[x&pmask] = 0
+			} else {
+				model[x&pmask] -= n
+			}
+		default:
+			n := uint64(r.Intn(1200))
+			err := f.InsertN(x, n)
+			if err != nil {
+				t.Fatalf("[%v] want InsertN(0x%X, %v) err = nil, got %v", i, x, n, err)
+			}
+			model[x&pmask] += n
+		}
+	}
+
+	for x, n := range model {
+		if n != f.Count(x) {
+			t.Errorf("want Count(0x%X) = %v, got %v", x, n, f.Count(x))
+		}
+		if (n > 0) != f.MayContainHash(x) {
+			t.Errorf("want MayContainHash(0x%X) = %v, got %v", x, n > 0, !(n > 0))
+		}
+	}
+
+	for bi := uint64(0); bi < f.blocks(); bi++ {
+		expected := naiveOffset(f, bi)
+		if expected != f.offset(bi) {
+			t.Errorf("want offset(%v) = %v, got %v", bi, expected, f.offset(bi))
+		}
+	}
+}
+
+// dropRunends clears the runends from the block of home slot h0 to the end of
+// the filter so the run for h0 has no end.
+func dropRunends(q *Rsqf, h0 uint64) {
+	for bi := h0 / blockLen; bi < q.blocks(); bi++ {
+		q.block(bi).Runends = 0
+	}
+}
+
+func Test_lookups_should_not_read_past_a_missing_runend(t *testing.T) {
+	t.Parallel()
+	// q = 10 and r = 9, x is in the last home slot.
+	x := uint64(0x3FF<<9 | 5)
+
+	c := NewCounting(1000)
+	c.InsertN(x, 2)
+	dropRunends(c, 0x3FF)
+	if c.Count(x) != 0 || c.MayContainHash(x) {
+		t.Errorf("want Count() = 0 and MayContainHash() = false, got %v, %v", c.Count(x), c.MayContainHash(x))
+	}
+
+	err := c.InsertN(x, 1)
+	if err != ErrCorrupt {
+		t.Errorf("want InsertN() err = ErrCorrupt, got %v", err)
+	}
+
+	f := New(1000)
+	f.Insert(x)
+	dropRunends(f, 0x3FF)
+	ok, err := f.Delete(x)
+	if ok || err != nil || f.MayContainHash(x) {
+		t.Errorf("want Delete() = false, nil and MayContainHash() = false, got %v, %v, %v", ok, err, f.MayContainHash(x))
+	}
+
+	m, _ := NewQuotientMap(Config{N: 1000}, 4)
+	m.Put(x, 3)
+	h0, _ := m.f.split(m.slot(x, 0))
+	dropRunends(m.f, h0)
+	_, ok = m.Get(x)
+	if ok {
+		t.Error("want QuotientMap Get() ok = false, got true")
+	}
+
+	e, _ := NewExpandable(Config{N: 1000})
+	e.Insert(x)
+	h0, _ = e.split(x)
+	dropRunends(e.f, h0)
+	_, _, ok = e.find(x)
+	if ok {
+		t.Error("want ExpandableRsqf find() ok = false, got true")
+	}
+}
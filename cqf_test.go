@@ -0,0 +1,147 @@
+package rsqf_test
+
+import (
+	"testing"
+
+	. "github.com/nfisher/rsqf"
+)
+
+func Test_InsertN_should_accumulate_counts(t *testing.T) {
+	t.Parallel()
+	f := NewCounting(100000)
+
+	td := [][]uint64{
+		// n, expected count
+		{1, 1},
+		{1, 2},
+		{1, 3},
+		{5, 8},
+		{1000, 1008},
+		{1 << 40, 1<<40 + 1008},
+	}
+
+	for i, v := range td {
+		err := f.InsertN(0x01F0, v[0])
+		if err != nil {
+			t.Errorf("[%v] want InsertN(0x01F0, %v) err = nil, got %v", i, v[0], err)
+		}
+
+		c := f.Count(0x01F0)
+		if v[1] != c {
+			t.Errorf("[%v] want Count(0x01F0) = %v, got %v", i, v[1], c)
+		}
+	}
+}
+
+func Test_Insert_counting_should_store_counters(t *testing.T) {
+	t.Parallel()
+	f := NewCounting(100000)
+	for i := 0; i < 4; i++ {
+		f.Insert(0x0005)
+	}
+	f.Insert(0x0007)
+	f.Insert(0x0209)
+
+	// 5 0x02 5 | 7 | 9
+	td := []uint64{0x05, 0x02, 0x05, 0x07, 0x09}
+	for i, v := range td {
+		if v != f.Get(uint64(i)) {
+			t.Errorf("want Get(%v) = 0x%X, got 0x%X", i, v, f.Get(uint64(i)))
+		}
+	}
+
+	if 0x18 != f.Block(0).Runends {
+		t.Errorf("want Block(0).Runends = 0x18, got 0x%X", f.Block(0).Runends)
+	}
+
+	counts := [][]uint64{{0x0005, 4}, {0x0007, 1}, {0x0209, 1}, {0x0006, 0}, {0x0009, 0}}
+	for i, v := range counts {
+		if v[1] != f.Count(v[0]) {
+			t.Errorf("[%v] want Count(0x%X) = %v, got %v", i, v[0], v[1], f.Count(v[0]))
+		}
+	}
+}
+
+func Test_DeleteN_should_decrement_counts(t *testing.T) {
+	t.Parallel()
+	f := NewCounting(100000)
+	f.InsertN(0x0000, 10)
+	f.InsertN(0x0001, 700)
+	f.InsertN(0x0002, 3)
+
+	td := []struct {
+		x, n  uint64
+		ok    bool
+		count uint64
+	}{
+		{0x0001, 200, true, 500},
+		{0x0001, 498, true, 2},
+		{0x0001, 1, true, 1},
+		{0x0000, 7, true, 3},
+		{0x0000, 5, true, 0},
+		{0x0000, 1, false, 0},
+		{0x0001, 9, true, 0},
+	}
+
+	for i, v := range td {
+		ok, err := f.DeleteN(v.x, v.n)
+		if v.ok != ok || err != nil {
+			t.Errorf("[%v] want DeleteN(0x%X, %v) = %v, nil, got %v, %v", i, v.x, v.n, v.ok, ok, err)
+		}
+
+		if v.count != f.Count(v.x) {
+			t.Errorf("[%v] want Count(0x%X) = %v, got %v", i, v.x, v.count, f.Count(v.x))
+		}
+	}
+
+	if 3 != f.Count(0x0002) {
+		t.Errorf("want Count(0x0002) = 3, got %v", f.Count(0x0002))
+	}
+
+	ok, _ := f.DeleteN(0x0002, 3)
+	if !ok {
+		t.Error("want DeleteN(0x0002, 3) = true, got false")
+	}
+
+	if 0 != f.Block(0).Occupieds || 0 != f.Block(0).Runends || 0 != f.Get(0) {
+		t.Errorf("want block 0 to be empty, got %+v", f.Block(0))
+	}
+}
+
+func Test_Count_without_counting(t *testing.T) {
+	t.Parallel()
+	f := New(100000)
+	f.InsertN(0x01F0, 3)
+	f.Insert(0x01F1)
+
+	if 3 != f.Count(0x01F0) {
+		t.Errorf("want Count(0x01F0) = 3, got %v", f.Count(0x01F0))
+	}
+
+	ok, _ := f.DeleteN(0x01F0, 5)
+	if !ok {
+		t.Error("want DeleteN(0x01F0, 5) = true, got false")
+	}
+
+	if 0 != f.Count(0x01F0) || 1 != f.Count(0x01F1) {
+		t.Errorf("want Count(0x01F0) = 0 and Count(0x01F1) = 1, got %v and %v",
+			f.Count(0x01F0), f.Count(0x01F1))
+	}
+}
+
+func Test_Items_should_track_instances(t *testing.T) {
+	t.Parallel()
+	td := []*Rsqf{New(1000), NewCounting(1000)}
+
+	for i, f := range td {
+		f.InsertN(0x0205, 5)
+		f.Insert(0x0001)
+		f.DeleteN(0x0205, 2)
+		f.DeleteN(0x0001, 4)
+		f.Delete(0x0003)
+
+		if 3 != f.Items() {
+			t.Errorf("[%v] want Items() = 3, got %v", i, f.Items())
+		}
+	}
+}
@@ -0,0 +1,417 @@
+package rsqf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"math"
+)
+
+/*
+The binary format is little-endian with a fixed 40 byte header followed by the
+blocks and the checksums. Each block record matches the layout of a block in
+Rsqf.Q, its metadata followed by its remainder words, so a mapped filter uses
+the blocks in place;
+
+	offset  size  field
+	0       4     magic "RSQF"
+	4       2     format version
+	6       1     flags, bit 0 is set for counting filters
+	7       1     hasher id
+	8       1     p
+	9       1     r
+	10      1     log2 of blocks per checksum range (version 2)
+	11      1     reserved
+	12      4     header CRC32C with this field zeroed (version 2)
+	16      8     hasher seed, the shift of an ordered hasher
+	24      8     item count
+	32      8     number of blocks, including the overflow blocks (version 3)
+	40      8sn   blocks of s = 3 + r words (offset, occupieds, runends,
+	              remainders) (version 4)
+	        4c    CRC32C of each range of blocks (version 2)
+
+Versions 1 to 3 store 24 byte block records without the remainders followed
+by every remainder word, ReadFrom interleaves them but they can't be mapped.
+Version 1 has no checksums. Versions 1 and 2 have no overflow blocks after the
+last home slot, ReadFrom appends empty ones.
+*/
+const (
+	formatMagic   = "RSQF"
+	formatVersion = 4
+	headerLen     = 40
+
+	// blockRecLen is the length of a block record without its remainders in
+	// versions 1 to 3.
+	blockRecLen = 24
+
+	flagCounting = 0x01
+
+	// crcRangeBits is log2 of the number of blocks covered by each checksum.
+	crcRangeBits = 10
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// ErrInvalidFormat is returned when decoding data that isn't a valid filter.
+var ErrInvalidFormat = errors.New("RSQF invalid binary format")
+
+// ErrUnsupportedVersion is returned when decoding a format version that this
+// package doesn't understand.
+var ErrUnsupportedVersion = errors.New("RSQF unsupported format version")
+
+// ErrChecksum is returned when decoding data that doesn't match its checksum.
+var ErrChecksum = errors.New("RSQF checksum mismatch")
+
+// header is the decoded form of the binary header.
+type header struct {
+	version  uint16
+	flags    uint8
+	hasherID HasherID
+	p        uint8
+	r        uint8
+	crcBits  uint8
+	seed     uint64
+	items    uint64
+	blocks   uint64
+}
+
+func (h *header) encode(b []byte) {
+	for i := range b[:headerLen] {
+		b[i] = 0
+	}
+	copy(b[0:4], formatMagic)
+	binary.LittleEndian.PutUint16(b[4:6], h.version)
+	b[6] = h.flags
+	b[7] = uint8(h.hasherID)
+	b[8] = h.p
+	b[9] = h.r
+	b[10] = h.crcBits
+	binary.LittleEndian.PutUint64(b[16:24], h.seed)
+	binary.LittleEndian.PutUint64(b[24:32], h.items)
+	binary.LittleEndian.PutUint64(b[32:40], h.blocks)
+	binary.LittleEndian.PutUint32(b[12:16], crc32.Checksum(b[:headerLen], castagnoli))
+}
+
+func (h *header) decode(b []byte) error {
+	if string(b[0:4]) != formatMagic {
+		return ErrInvalidFormat
+	}
+
+	h.version = binary.LittleEndian.Uint16(b[4:6])
+	if h.version < 1 || h.version > formatVersion {
+		return ErrUnsupportedVersion
+	}
+
+	if h.version >= 2 {
+		var c [headerLen]byte
+		copy(c[:], b)
+		binary.LittleEndian.PutUint32(c[12:16], 0)
+		if crc32.Checksum(c[:], castagnoli) != binary.LittleEndian.Uint32(b[12:16]) {
+			return ErrChecksum
+		}
+	}
+
+	h.flags = b[6]
+	h.hasherID = HasherID(b[7])
+	h.p = b[8]
+	h.r = b[9]
+	h.crcBits = b[10]
+	h.seed = binary.LittleEndian.Uint64(b[16:24])
+	h.items = binary.LittleEndian.Uint64(b[24:32])
+	h.blocks = binary.LittleEndian.Uint64(b[32:40])
+
+	// the block count must agree with the quotient newRsqf would use.
+	if h.p > 64 || h.r < 1 || h.p < h.r+blockBits || h.flags&^flagCounting != 0 {
+		return ErrInvalidFormat
+	}
+	if h.blocks != h.blocksFor() {
+		return ErrInvalidFormat
+	}
+	if h.version >= 2 && h.crcBits > 58 {
+		return ErrInvalidFormat
+	}
+
+	return nil
+}
+
+// blocksFor returns the number of blocks stored for the header's quotient.
+func (h *header) blocksFor() uint64 {
+	q := uint64(h.p - h.r)
+	if h.version < 3 {
+		return pow2(q) / blockLen
+	}
+	return blocksFor(q)
+}
+
+// perRange returns the number of blocks covered by each checksum. Version 1
+// is treated as a single range without a checksum.
+func (h *header) perRange() uint64 {
+	if h.version < 2 || pow2(uint64(h.crcBits)) > h.blocks {
+		return h.blocks
+	}
+	return pow2(uint64(h.crcBits))
+}
+
+// ranges returns the number of checksums following the remainders.
+func (h *header) ranges() uint64 {
+	if h.version < 2 {
+		return 0
+	}
+	return (h.blocks + h.perRange() - 1) / h.perRange()
+}
+
+func (q *Rsqf) header() header {
+	h := header{
+		version:  formatVersion,
+		hasherID: q.hasher.ID(),
+		p:        uint8(q.p),
+		r:        uint8(q.remainder),
+		crcBits:  crcRangeBits,
+		seed:     hasherParam(q.hasher),
+		items:    q.items,
+		blocks:   q.blocks(),
+	}
+	if q.counting {
+		h.flags |= flagCounting
+	}
+	return h
+}
+
+// MarshalBinary encodes the filter into the binary format.
+func (q *Rsqf) MarshalBinary() ([]byte, error) {
+	h := q.header()
+	var buf bytes.Buffer
+	buf.Grow(headerLen + len(q.Q)*8 + int(h.ranges())*4)
+
+	_, err := q.WriteTo(&buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary replaces the filter with the one encoded in data.
+func (q *Rsqf) UnmarshalBinary(data []byte) error {
+	r := bytes.NewReader(data)
+	_, err := q.ReadFrom(r)
+	if err != nil {
+		return err
+	}
+
+	if r.Len() != 0 {
+		return ErrInvalidFormat
+	}
+	return nil
+}
+
+// chunkLen is the maximum number of words encoded per write.
+const chunkLen = 512
+
+// chunk returns the number of records starting at record i of n to encode at
+// once. A chunk never crosses into the next checksum range of per records.
+func chunk(i, n, per uint64) uint64 {
+	c := per - i%per
+	if c > chunkLen {
+		c = chunkLen
+	}
+	if n-i < c {
+		c = n - i
+	}
+	return c
+}
+
+// WriteTo writes the filter to w in the binary format.
+func (q *Rsqf) WriteTo(w io.Writer) (int64, error) {
+	var total int64
+	err := q.finishGrow()
+	if err != nil {
+		return total, err
+	}
+
+	buf := make([]byte, chunkLen*8)
+
+	h := q.header()
+	h.encode(buf)
+	n, err := w.Write(buf[:headerLen])
+	total += int64(n)
+	if err != nil {
+		return total, err
+	}
+
+	per := h.perRange() * (blockMeta + q.remainder)
+	crcs := make([]uint32, h.ranges())
+
+	words := uint64(len(q.Q))
+	for i := uint64(0); i < words; {
+		c := chunk(i, words, per)
+
+		b := buf[:c*8]
+		for j, v := range q.Q[i : i+c] {
+			binary.LittleEndian.PutUint64(b[j*8:], v)
+		}
+		crcs[i/per] = crc32.Update(crcs[i/per], castagnoli, b)
+
+		n, err := w.Write(b)
+		total += int64(n)
+		if err != nil {
+			return total, err
+		}
+		i += c
+	}
+
+	for i := 0; i < len(crcs); i += chunkLen {
+		c := crcs[i:]
+		if len(c) > chunkLen {
+			c = c[:chunkLen]
+		}
+
+		b := buf[:len(c)*4]
+		for j, v := range c {
+			binary.LittleEndian.PutUint32(b[j*4:], v)
+		}
+
+		n, err := w.Write(b)
+		total += int64(n)
+		if err != nil {
+			return total, err
+		}
+	}
+
+	return total, nil
+}
+
+// ReadFrom replaces the filter with one read from r in the binary format. The
+// slices grow as data is read so a corrupt header can't force a large
+// allocation.
+func (q *Rsqf) ReadFrom(r io.Reader) (int64, error) {
+	var total int64
+	buf := make([]byte, chunkLen*8)
+
+	n, err := io.ReadFull(r, buf[:headerLen])
+	total += int64(n)
+	if err != nil {
+		return total, noEOF(err)
+	}
+
+	var h header
+	err = h.decode(buf[:headerLen])
+	if err != nil {
+		return total, err
+	}
+
+	hasher, err := NewHasher(h.hasherID, h.seed)
+	if err != nil {
+		return total, err
+	}
+
+	err = checkOrdered(hasher, uint64(h.p))
+	if err != nil {
+		return total, err
+	}
+
+	d := wordReader{r: r, buf: buf, sum: h.version >= 2}
+	stride := blockMeta + uint64(h.r)
+	var words []uint64
+	if h.version >= 4 {
+		words = d.read(h.blocks*stride, h.perRange()*stride)
+	} else {
+		// the blocks are followed by their remainders, the checksum of a
+		// range covers both.
+		recs := d.read(h.blocks*blockMeta, h.perRange()*blockMeta)
+		remainders := d.read(h.blocks*uint64(h.r), h.perRange()*uint64(h.r))
+		for bi := uint64(0); bi < uint64(len(remainders))/uint64(h.r); bi++ {
+			words = append(words, recs[bi*blockMeta]&offsetMax)
+			words = append(words, recs[bi*blockMeta+1:(bi+1)*blockMeta]...)
+			words = append(words, remainders[bi*uint64(h.r):(bi+1)*uint64(h.r)]...)
+		}
+	}
+	total += d.total
+	if d.err != nil {
+		return total, noEOF(d.err)
+	}
+
+	for i := 0; i < len(d.crcs); {
+		c := int(chunk(uint64(i), uint64(len(d.crcs)), math.MaxUint64))
+
+		b := buf[:c*4]
+		n, err := io.ReadFull(r, b)
+		total += int64(n)
+		if err != nil {
+			return total, noEOF(err)
+		}
+
+		for j := 0; j < c; j++ {
+			if d.crcs[i+j] != binary.LittleEndian.Uint32(b[j*4:]) {
+				return total, ErrChecksum
+			}
+		}
+		i += c
+	}
+
+	// earlier versions end at the last home slot.
+	for i := h.blocks; i < blocksFor(uint64(h.p-h.r)); i++ {
+		words = append(words, make([]uint64, stride)...)
+	}
+
+	filter := sizedRsqf(uint64(h.p), uint64(h.r))
+	filter.Q = words
+	filter.counting = h.flags&flagCounting != 0
+	filter.hasher = hasher
+	filter.items = h.items
+	filter.used = filter.usedSlots()
+	*q = *filter
+
+	return total, nil
+}
+
+// wordReader reads little-endian words in chunks and sums the checksum of
+// each range. The first error stops every later read.
+type wordReader struct {
+	r     io.Reader
+	buf   []byte
+	sum   bool
+	crcs  []uint32
+	total int64
+	err   error
+}
+
+// read returns n words, per of them in each checksum range. Each call starts
+// again at the first range.
+func (d *wordReader) read(n, per uint64) []uint64 {
+	var words []uint64
+	for i := uint64(0); i < n && d.err == nil; {
+		c := chunk(i, n, per)
+
+		b := d.buf[:c*8]
+		m, err := io.ReadFull(d.r, b)
+		d.total += int64(m)
+		if err != nil {
+			d.err = err
+			return nil
+		}
+
+		for j := uint64(0); j < c; j++ {
+			words = append(words, binary.LittleEndian.Uint64(b[j*8:]))
+		}
+
+		if d.sum {
+			ri := int(i / per)
+			if ri == len(d.crcs) {
+				d.crcs = append(d.crcs, 0)
+			}
+			d.crcs[ri] = crc32.Update(d.crcs[ri], castagnoli, b)
+		}
+		i += c
+	}
+	return words
+}
+
+// noEOF converts an EOF part way through a filter into ErrUnexpectedEOF.
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,221 @@
+package rsqf_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	. "github.com/nfisher/rsqf"
+)
+
+func Test_MarshalBinary_roundtrip(t *testing.T) {
+	t.Parallel()
+	f, err := NewWithConfig(Config{N: 10000, ErrRate: 1.0 / 1024.0, Counting: true, Hasher: NewMurmur3(17)})
+	if err != nil {
+		t.Fatalf("want NewWithConfig() err = nil, got %v", err)
+	}
+
+	r := rand.New(rand.NewSource(1))
+	inserted := make([]uint64, 2000)
+	for i := range inserted {
+		inserted[i] = r.Uint64()
+		f.InsertN(inserted[i], uint64(i%4+1))
+	}
+
+	b, err := f.MarshalBinary()
+	if err != nil {
+		t.Fatalf("want MarshalBinary() err = nil, got %v", err)
+	}
+
+	// one checksum for every 1024 blocks of 3 + r words.
+	blocks := int(binary.LittleEndian.Uint64(b[32:40]))
+	expected := 40 + len(f.Q)*8 + (blocks+1023)/1024*4
+	if expected != len(b) {
+		t.Errorf("want len(MarshalBinary()) = %v, got %v", expected, len(b))
+	}
+
+	if "RSQF" != string(b[:4]) {
+		t.Errorf("want magic = RSQF, got %q", b[:4])
+	}
+
+	var g Rsqf
+	err = g.UnmarshalBinary(b)
+	if err != nil {
+		t.Fatalf("want UnmarshalBinary() err = nil, got %v", err)
+	}
+
+	if !reflect.DeepEqual(f.Q, g.Q) {
+		t.Error("want Q to be equal after UnmarshalBinary()")
+	}
+
+	if f.Items() != g.Items() {
+		t.Errorf("want Items() = %v, got %v", f.Items(), g.Items())
+	}
+
+	if err := g.CheckHasher(NewMurmur3(17)); err != nil {
+		t.Errorf("want CheckHasher() = nil, got %v", err)
+	}
+
+	for i, x := range inserted {
+		if f.Count(x) != g.Count(x) {
+			t.Fatalf("[%v] want Count(0x%X) = %v, got %v", i, x, f.Count(x), g.Count(x))
+		}
+	}
+
+	err = g.InsertN(inserted[0], 3)
+	if err != nil || f.Count(inserted[0])+3 != g.Count(inserted[0]) {
+		t.Errorf("want InsertN() to update the decoded filter, got %v", err)
+	}
+}
+
+func Test_WriteTo_ReadFrom_roundtrip(t *testing.T) {
+	t.Parallel()
+	f := New(100000)
+	td := []string{"Hello world", "hello world", "", "rank", "select"}
+	for _, v := range td {
+		f.InsertString(v)
+	}
+
+	var buf bytes.Buffer
+	n, err := f.WriteTo(&buf)
+	if err != nil || n != int64(buf.Len()) {
+		t.Fatalf("want WriteTo() = %v, nil, got %v, %v", buf.Len(), n, err)
+	}
+
+	var g Rsqf
+	m, err := g.ReadFrom(&buf)
+	if err != nil || m != n {
+		t.Fatalf("want ReadFrom() = %v, nil, got %v, %v", n, m, err)
+	}
+
+	for i, v := range td {
+		if !g.MayContainString(v) {
+			t.Errorf("[%v] want MayContainString(%q) = true, got false", i, v)
+		}
+	}
+
+	if uint64(len(td)) != g.Items() {
+		t.Errorf("want Items() = %v, got %v", len(td), g.Items())
+	}
+}
+
+func Test_UnmarshalBinary_invalid_data(t *testing.T) {
+	t.Parallel()
+	f := New(1000)
+	b, _ := f.MarshalBinary()
+
+	// corrupt sets byte i to v and updates the header checksum.
+	corrupt := func(i int, v byte) []byte {
+		c := append([]byte(nil), b...)
+		c[i] = v
+		if i < 12 || (i >= 16 && i < 40) {
+			binary.LittleEndian.PutUint32(c[12:16], 0)
+			binary.LittleEndian.PutUint32(c[12:16], crc32.Checksum(c[:40], crc32.MakeTable(crc32.Castagnoli)))
+		}
+		return c
+	}
+
+	td := []struct {
+		name     string
+		data     []byte
+		expected error
+	}{
+		{"empty", nil, io.ErrUnexpectedEOF},
+		{"magic", corrupt(0, 'X'), ErrInvalidFormat},
+		{"version", corrupt(4, 9), ErrUnsupportedVersion},
+		{"flags", corrupt(6, 0x80), ErrInvalidFormat},
+		{"hasher", corrupt(7, 0), ErrUnknownHasher},
+		{"ordered shift", corrupt(7, byte(Ordered)), ErrInvalidShift},
+		{"p", corrupt(8, 65), ErrInvalidFormat},
+		{"r", corrupt(9, 0), ErrInvalidFormat},
+		{"blocks", corrupt(32, 1), ErrInvalidFormat},
+		{"header checksum", corrupt(12, b[12]+1), ErrChecksum},
+		{"block checksum", corrupt(48, 1), ErrChecksum},
+		{"remainder checksum", corrupt(len(b)-5, 1), ErrChecksum},
+		{"range checksum", corrupt(len(b)-1, b[len(b)-1]+1), ErrChecksum},
+		{"truncated", b[:len(b)-1], io.ErrUnexpectedEOF},
+		{"trailing", append(append([]byte(nil), b...), 0), ErrInvalidFormat},
+	}
+
+	for _, v := range td {
+		var g Rsqf
+		err := g.UnmarshalBinary(v.data)
+		if v.expected != err {
+			t.Errorf("[%v] want UnmarshalBinary() err = %v, got %v", v.name, v.expected, err)
+		}
+	}
+}
+
+// legacy re-encodes the filter in b as format version 1, 2 or 3 which store
+// the block records apart from the remainders. Versions 1 and 2 end at the last
+// home slot.
+func legacy(b []byte, version uint16) []byte {
+	castagnoli := crc32.MakeTable(crc32.Castagnoli)
+	r := int(b[9])
+	stride := (3 + r) * 8
+	blocks := int(binary.LittleEndian.Uint64(b[32:40]))
+	if version < 3 {
+		blocks = (1 << uint(b[8]-b[9])) / 64
+	}
+
+	c := append([]byte(nil), b[:40]...)
+	for bi := 0; bi < blocks; bi++ {
+		c = append(c, b[40+bi*stride:40+bi*stride+24]...)
+	}
+	for bi := 0; bi < blocks; bi++ {
+		c = append(c, b[40+bi*stride+24:40+(bi+1)*stride]...)
+	}
+	binary.LittleEndian.PutUint16(c[4:6], version)
+	binary.LittleEndian.PutUint64(c[32:40], uint64(blocks))
+
+	// version 1 has no checksums.
+	if version == 1 {
+		for i := 10; i < 16; i++ {
+			c[i] = 0
+		}
+		return c
+	}
+
+	// the filters are small enough for a single checksum range.
+	var crc [4]byte
+	binary.LittleEndian.PutUint32(crc[:], crc32.Checksum(c[40:], castagnoli))
+	binary.LittleEndian.PutUint32(c[12:16], 0)
+	binary.LittleEndian.PutUint32(c[12:16], crc32.Checksum(c[:40], castagnoli))
+	return append(c, crc[:]...)
+}
+
+func Test_UnmarshalBinary_should_add_overflow_blocks_to_earlier_versions(t *testing.T) {
+	t.Parallel()
+	td := []uint16{1, 2, 3}
+
+	for _, version := range td {
+		f := New(1000)
+		f.InsertString("Hello world")
+		f.Insert(0x3FF << 9)
+		b, _ := f.MarshalBinary()
+
+		var g Rsqf
+		err := g.UnmarshalBinary(legacy(b, version))
+		if err != nil {
+			t.Fatalf("[%v] want UnmarshalBinary() err = nil, got %v", version, err)
+		}
+
+		if !g.MayContainString("Hello world") {
+			t.Errorf("[%v] want MayContainString(\"Hello world\") = true, got false", version)
+		}
+
+		err = g.Insert(0x3FF<<9 | 0x01)
+		if err != nil {
+			t.Errorf("[%v] want Insert() into an overflow slot err = nil, got %v", version, err)
+		}
+
+		err = g.Verify()
+		if err != nil {
+			t.Errorf("[%v] want Verify() = nil, got %v", version, err)
+		}
+	}
+}
@@ -0,0 +1,333 @@
+package rsqf
+
+import (
+	"math"
+	"math/bits"
+)
+
+// expandLoad is the default fraction of used slots at which an
+// ExpandableRsqf expands.
+const expandLoad = 0.95
+
+/*
+ExpandableRsqf is a filter that doubles its slots by moving a bit from each
+stored fingerprint into the quotient, following InfiniFilter by Dayan et al.
+Unlike Resize the universe p grows with every expansion so entries inserted
+afterwards keep a full fingerprint while older entries lose a bit each time.
+
+The home slot is taken from the top q bits of the hash and the fingerprint
+from the bits below it. A remainder slot of r bits holds a fingerprint f of
+length l < r followed by a 1 and zero padding so the length can be recovered;
+
+	slot = f << (r - l) | 1 << (r - l - 1)
+
+An expansion makes the top bit of f the lowest bit of the new home slot. An
+entry with no fingerprint bits left matches any key with its home slot. It
+can't be split between the new home slots so like InfiniFilter it's moved out
+of the filter and kept with the number of quotient bits it had.
+*/
+type ExpandableRsqf struct {
+	f          *Rsqf // fingerprints are stored as h0 << r | slot.
+	at         float64
+	items      uint64
+	expansions int
+
+	// voids counts the entries without fingerprint bits by the number of
+	// quotient bits they had and their home slot.
+	voids map[uint64]map[uint64]uint64
+}
+
+// NewExpandable returns an ExpandableRsqf for c.N insertions. Fresh entries
+// have a false-positive rate of c.ErrRate, an extra remainder bit is used to
+// record the fingerprint length. c.GrowAt is the load factor to expand at,
+// defaults to 0.95. Counting isn't supported.
+func NewExpandable(c Config) (*ExpandableRsqf, error) {
+	rate := c.ErrRate
+	if rate == 0 {
+		rate = errRate
+	}
+
+	if rate <= 0 || rate >= 1 {
+		return nil, ErrInvalidRate
+	}
+
+	if c.N < 1 || c.Counting {
+		return nil, ErrInvalidSize
+	}
+
+	at := c.GrowAt
+	if at == 0 {
+		at = expandLoad
+	}
+	if at < 0 || at > 1 {
+		return nil, ErrInvalidGrowAt
+	}
+
+	r := math.Ceil(math.Log2(1/rate)) + 1
+	q := math.Max(math.Ceil(math.Log2(c.N)), blockBits)
+	if q+r > 64 {
+		return nil, ErrInvalidSize
+	}
+
+	f := newRsqf(uint64(q+r), uint64(r))
+	if c.Hasher != nil {
+		f.hasher = c.Hasher
+	}
+
+	return &ExpandableRsqf{f: f, at: at, voids: make(map[uint64]map[uint64]uint64)}, nil
+}
+
+// Items returns the number of instances stored in the filter.
+func (e *ExpandableRsqf) Items() uint64 {
+	return e.items
+}
+
+// Expansions returns the number of times the filter has expanded.
+func (e *ExpandableRsqf) Expansions() int {
+	return e.expansions
+}
+
+// Hash applies the filter's hashing algorithm to b.
+func (e *ExpandableRsqf) Hash(b []byte) uint64 {
+	return e.f.Hash(b)
+}
+
+// split returns the home slot and the full length fingerprint of the hash x,
+// the fingerprint is r - 1 bits.
+func (e *ExpandableRsqf) split(x uint64) (uint64, uint64) {
+	q, r := e.f.quotient, e.f.remainder
+	h0 := x >> (64 - q)
+	fp := x << q >> (64 - r + 1)
+	return h0, fp
+}
+
+// matches returns true if the fingerprint stored in slot v is a prefix of the
+// full length fingerprint fp.
+func (e *ExpandableRsqf) matches(v, fp uint64) bool {
+	r := e.f.remainder
+	pad := uint64(bits.TrailingZeros64(v)) + 1
+	l := r - pad
+	return v>>pad == fp>>(r-1-l)
+}
+
+// find returns the slot of the longest stored fingerprint that matches x.
+func (e *ExpandableRsqf) find(x uint64) (h0, s uint64, ok bool) {
+	q := e.f
+	h0, fp := e.split(x)
+	if !q.isOccupied(h0) {
+		return h0, 0, false
+	}
+
+	end, found := q.lastEnd(h0)
+	if !found || end >= q.slots() {
+		return h0, 0, false
+	}
+	var pad uint64 = 64
+	for i := q.runStart(h0, end); i <= end; i++ {
+		v := q.Get(i)
+		if e.matches(v, fp) && uint64(bits.TrailingZeros64(v)) < pad {
+			s, pad, ok = i, uint64(bits.TrailingZeros64(v)), true
+		}
+	}
+	return h0, s, ok
+}
+
+// void returns the number of quotient bits of the longest void entry whose
+// home slot is a prefix of the hash x.
+func (e *ExpandableRsqf) void(x uint64) (uint64, bool) {
+	var longest uint64
+	for q, slots := range e.voids {
+		if q > longest && slots[x>>(64-q)] > 0 {
+			longest = q
+		}
+	}
+	return longest, longest > 0
+}
+
+// Insert places the hash x into the filter. The filter expands first if it
+// is over its load factor or x doesn't fit. It expands at most once so an
+// insert that still doesn't fit returns ErrFilterOverflow.
+func (e *ExpandableRsqf) Insert(x uint64) error {
+	var expanded bool
+	if float64(e.f.used+1) > e.at*float64(e.f.homeSlots()) {
+		// a filter that can't expand is filled until it overflows.
+		expanded = e.Expand() == nil
+	}
+
+	h0, fp := e.split(x)
+	err := e.f.Insert(h0<<e.f.remainder | fp<<1 | 1)
+	if err == ErrFilterOverflow && !expanded && e.Expand() == nil {
+		h0, fp = e.split(x)
+		err = e.f.Insert(h0<<e.f.remainder | fp<<1 | 1)
+	}
+	if err != nil {
+		return err
+	}
+
+	e.items++
+	return nil
+}
+
+// InsertBytes hashes b and inserts the result into the filter.
+func (e *ExpandableRsqf) InsertBytes(b []byte) error {
+	return e.Insert(e.Hash(b))
+}
+
+// InsertString hashes s and inserts the result into the filter.
+func (e *ExpandableRsqf) InsertString(s string) error {
+	return e.InsertBytes([]byte(s))
+}
+
+// Delete removes the longest fingerprint that matches the hash x so shorter
+// fingerprints that may belong to other keys are kept. Void entries are
+// shorter than any in the filter so they are only removed if there's no
+// match in the filter. It returns false if x was not found.
+func (e *ExpandableRsqf) Delete(x uint64) (bool, error) {
+	h0, s, ok := e.find(x)
+	if !ok {
+		return e.deleteVoid(x), nil
+	}
+
+	err := e.f.removeSlot(h0, s)
+	if err != nil {
+		return false, err
+	}
+	e.f.items--
+	e.items--
+
+	return true, nil
+}
+
+// deleteVoid removes the longest void entry that matches the hash x.
+func (e *ExpandableRsqf) deleteVoid(x uint64) bool {
+	q, ok := e.void(x)
+	if !ok {
+		return false
+	}
+
+	slots := e.voids[q]
+	h0 := x >> (64 - q)
+	slots[h0]--
+	if slots[h0] == 0 {
+		delete(slots, h0)
+	}
+	if len(slots) == 0 {
+		delete(e.voids, q)
+	}
+	e.items--
+
+	return true
+}
+
+// MayContainHash tests if the hash x exists in this filter.
+func (e *ExpandableRsqf) MayContainHash(x uint64) bool {
+	_, _, ok := e.find(x)
+	if ok {
+		return true
+	}
+	_, ok = e.void(x)
+	return ok
+}
+
+// MayContain tests if b exists in this filter.
+func (e *ExpandableRsqf) MayContain(b []byte) bool {
+	return e.MayContainHash(e.Hash(b))
+}
+
+// MayContainString tests if s exists in this filter.
+func (e *ExpandableRsqf) MayContainString(s string) bool {
+	return e.MayContain([]byte(s))
+}
+
+// Verify checks the invariants of the underlying filter, see Rsqf.Verify,
+// and that the item count matches the entries stored.
+func (e *ExpandableRsqf) Verify() error {
+	err := e.f.Verify()
+	if err != nil {
+		return err
+	}
+
+	items := e.f.items
+	for _, slots := range e.voids {
+		for _, n := range slots {
+			items += n
+		}
+	}
+	if items != e.items {
+		return ErrCorrupt
+	}
+
+	return nil
+}
+
+// Expand doubles the number of slots by moving the top bit of every
+// fingerprint into its home slot. It returns ErrResizeUniverse once the
+// quotient and remainder would need more than 64 bits of the hash.
+func (e *ExpandableRsqf) Expand() error {
+	old := e.f
+	r := old.remainder
+	if old.p+1 > 64 {
+		return ErrResizeUniverse
+	}
+
+	filter := newRsqf(old.p+1, r)
+	filter.hasher = old.hasher
+
+	// an entry in run h0 moves to 2h0 or 2h0 + 1 so runs are rebuilt one at a
+	// time. Shifting a slot left keeps the order of the remaining bits.
+	void := pow2(r - 1)
+	voids := make(map[uint64]uint64)
+	var lo, hi []uint64
+	a := appender{q: filter}
+	add := func(h0 uint64) error {
+		for b, run := range [][]uint64{lo, hi} {
+			for _, v := range run {
+				err := a.add((h0<<1|uint64(b))<<r|v, 1)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		lo, hi = lo[:0], hi[:0]
+		return nil
+	}
+
+	var h0 uint64
+	c := cursor{q: old}
+	for x, _, ok := c.next(); ok; x, _, ok = c.next() {
+		if x>>r != h0 {
+			err := add(h0)
+			if err != nil {
+				return err
+			}
+			h0 = x >> r
+		}
+
+		v := x & old.rMask
+		switch {
+		case v == void:
+			voids[h0]++
+		case v&void == 0:
+			lo = append(lo, v<<1&old.rMask)
+		default:
+			hi = append(hi, v<<1&old.rMask)
+		}
+	}
+
+	err := add(h0)
+	if err != nil {
+		return err
+	}
+
+	err = a.finish()
+	if err != nil {
+		return err
+	}
+
+	if len(voids) > 0 {
+		e.voids[old.quotient] = voids
+	}
+	e.f = filter
+	e.expansions++
+	return nil
+}
@@ -0,0 +1,234 @@
+package rsqf_test
+
+import (
+	"testing"
+
+	. "github.com/nfisher/rsqf"
+)
+
+func Test_NewExpandable_should_reject_invalid_config(t *testing.T) {
+	t.Parallel()
+	td := []struct {
+		name string
+		c    Config
+		err  error
+	}{
+		{"rate", Config{N: 1000, ErrRate: 1.5}, ErrInvalidRate},
+		{"size", Config{N: 0}, ErrInvalidSize},
+		{"counting", Config{N: 1000, Counting: true}, ErrInvalidSize},
+		{"universe", Config{N: 1 << 40, ErrRate: 1.0 / (1 << 30)}, ErrInvalidSize},
+		{"grow at", Config{N: 1000, GrowAt: 2}, ErrInvalidGrowAt},
+	}
+
+	for _, tc := range td {
+		_, err := NewExpandable(tc.c)
+		if err != tc.err {
+			t.Errorf("%v want err = %v, got %v", tc.name, tc.err, err)
+		}
+	}
+}
+
+func Test_ExpandableRsqf_should_keep_keys_while_expanding(t *testing.T) {
+	t.Parallel()
+	e, err := NewExpandable(Config{N: 1000})
+	if err != nil {
+		t.Fatalf("want NewExpandable() err = nil, got %v", err)
+	}
+
+	keys := randomKeys(51, 50000)
+	for i, x := range keys {
+		err := e.Insert(x)
+		if err != nil {
+			t.Fatalf("[%v] want Insert(0x%X) err = nil, got %v", i, x, err)
+		}
+	}
+
+	if e.Expansions() < 5 {
+		t.Errorf("want Expansions() >= 5, got %v", e.Expansions())
+	}
+
+	if e.Items() != uint64(len(keys)) {
+		t.Errorf("want Items() = %v, got %v", len(keys), e.Items())
+	}
+
+	for i, x := range keys {
+		if !e.MayContainHash(x) {
+			t.Fatalf("[%v] want MayContainHash(0x%X) = true, got false", i, x)
+		}
+	}
+
+	err = e.Verify()
+	if err != nil {
+		t.Errorf("want Verify() = nil, got %v", err)
+	}
+
+	// entries added after the last expansion have a full fingerprint.
+	var fp int
+	queries := randomKeys(53, 100000)
+	for _, x := range queries {
+		if e.MayContainHash(x) {
+			fp++
+		}
+	}
+
+	rate := float64(fp) / float64(len(queries))
+	if rate >= 0.01 {
+		t.Errorf("want false-positive rate < 0.01, got %v", rate)
+	}
+}
+
+func Test_ExpandableRsqf_should_keep_void_entries(t *testing.T) {
+	t.Parallel()
+	// r = 3 so fresh entries have 2 fingerprint bits which are used up by the
+	// second expansion.
+	e, err := NewExpandable(Config{N: 64, ErrRate: 0.25, GrowAt: 1})
+	if err != nil {
+		t.Fatalf("want NewExpandable() err = nil, got %v", err)
+	}
+
+	keys := randomKeys(55, 40)
+	for i, x := range keys {
+		err := e.Insert(x)
+		if err != nil {
+			t.Fatalf("[%v] want Insert(0x%X) err = nil, got %v", i, x, err)
+		}
+	}
+
+	for n := 0; n < 4; n++ {
+		err := e.Expand()
+		if err != nil {
+			t.Fatalf("[%v] want Expand() err = nil, got %v", n, err)
+		}
+
+		if e.Items() != uint64(len(keys)) {
+			t.Fatalf("[%v] want Items() = %v, got %v", n, len(keys), e.Items())
+		}
+
+		for i, x := range keys {
+			if !e.MayContainHash(x) {
+				t.Fatalf("[%v, %v] want MayContainHash(0x%X) = true, got false", n, i, x)
+			}
+		}
+
+		err = e.Verify()
+		if err != nil {
+			t.Fatalf("[%v] want Verify() = nil, got %v", n, err)
+		}
+	}
+
+	for i, x := range keys {
+		ok, err := e.Delete(x)
+		if !ok || err != nil {
+			t.Fatalf("[%v] want Delete(0x%X) = true, nil, got %v, %v", i, x, ok, err)
+		}
+	}
+
+	if e.Items() != 0 {
+		t.Errorf("want Items() = 0, got %v", e.Items())
+	}
+
+	// a void entry is only stored once so deleting it removes every match.
+	for i, x := range keys {
+		if e.MayContainHash(x) {
+			t.Fatalf("[%v] want MayContainHash(0x%X) = false after Delete(), got true", i, x)
+		}
+	}
+
+	err = e.Verify()
+	if err != nil {
+		t.Errorf("want Verify() = nil, got %v", err)
+	}
+}
+
+func Test_ExpandableRsqf_should_bound_rate_and_items_after_expansions(t *testing.T) {
+	t.Parallel()
+	// r = 4 so fresh entries have 3 fingerprint bits which are used up by the
+	// third expansion.
+	e, err := NewExpandable(Config{N: 1024, ErrRate: 1.0 / 8, GrowAt: 1})
+	if err != nil {
+		t.Fatalf("want NewExpandable() err = nil, got %v", err)
+	}
+
+	keys := randomKeys(57, 1000)
+	for _, x := range keys {
+		e.Insert(x)
+	}
+
+	queries := randomKeys(59, 100000)
+	for k := 1; k <= 6; k++ {
+		err := e.Expand()
+		if err != nil {
+			t.Fatalf("[%v] want Expand() err = nil, got %v", k, err)
+		}
+
+		if e.Items() != uint64(len(keys)) {
+			t.Errorf("[%v] want Items() = %v, got %v", k, len(keys), e.Items())
+		}
+
+		err = e.Verify()
+		if err != nil {
+			t.Errorf("[%v] want Verify() = nil, got %v", k, err)
+		}
+
+		// once the fingerprints are used up each key matches its home slot,
+		// 1000 of 1024 home slots at the original size.
+		var fp int
+		for _, x := range queries {
+			if e.MayContainHash(x) {
+				fp++
+			}
+		}
+
+		rate := float64(fp) / float64(len(queries))
+		if rate >= 0.7 {
+			t.Errorf("[%v] want false-positive rate < 0.7, got %v", k, rate)
+		}
+	}
+}
+
+func Test_ExpandableRsqf_Insert_should_expand_at_most_once(t *testing.T) {
+	t.Parallel()
+	// r = 51 and q = 6 so the quotient can take 7 more bits.
+	e, err := NewExpandable(Config{N: 64, ErrRate: 1.0 / (1 << 50)})
+	if err != nil {
+		t.Fatalf("want NewExpandable() err = nil, got %v", err)
+	}
+
+	// every key has the same fingerprint in the last home slot so expanding
+	// never makes room.
+	for i := 0; i < 5000; i++ {
+		before := e.Expansions()
+		err = e.Insert(^uint64(0))
+		if e.Expansions() > before+1 {
+			t.Fatalf("[%v] want at most 1 expansion, got %v", i, e.Expansions()-before)
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	if err != ErrFilterOverflow {
+		t.Errorf("want Insert() err = ErrFilterOverflow, got %v", err)
+	}
+}
+
+func Test_ExpandableRsqf_should_stop_at_64_bits(t *testing.T) {
+	t.Parallel()
+	// r = 51 and q = 6 so the quotient can take 7 more bits.
+	e, err := NewExpandable(Config{N: 64, ErrRate: 1.0 / (1 << 50)})
+	if err != nil {
+		t.Fatalf("want NewExpandable() err = nil, got %v", err)
+	}
+
+	for n := 0; n < 7; n++ {
+		err := e.Expand()
+		if err != nil {
+			t.Fatalf("[%v] want Expand() err = nil, got %v", n, err)
+		}
+	}
+
+	err = e.Expand()
+	if err != ErrResizeUniverse {
+		t.Errorf("want Expand() err = ErrResizeUniverse, got %v", err)
+	}
+}
@@ -0,0 +1,16 @@
+package rsqf
+
+// Block returns the metadata of block bi for the external tests.
+func (q *Rsqf) Block(bi uint64) *block {
+	return q.block(bi)
+}
+
+// Remainders returns the remainder words of block bi for the external tests.
+func (q *Rsqf) Remainders(bi uint64) []uint64 {
+	return q.remainders(bi)
+}
+
+// Blocks returns the number of blocks for the external tests.
+func (q *Rsqf) Blocks() uint64 {
+	return q.blocks()
+}
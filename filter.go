@@ -0,0 +1,153 @@
+//go:build go1.18
+// +build go1.18
+
+package rsqf
+
+import (
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// ErrUnsupportedKey is returned when a Filter can't encode a key.
+var ErrUnsupportedKey = errors.New("RSQF unsupported key type")
+
+// Filter is a typed front-end to an Rsqf that hashes keys of type K with the
+// filter's hasher. Strings, []byte, encoding.BinaryMarshaler and fmt.Stringer
+// keys are hashed from their bytes. Integer keys, including named integers
+// with a String method, are mixed with an invertible function instead which
+// is much faster than hashing an encoding of them, filters with an ordered
+// hasher encode integers big-endian so their order is kept.
+//
+// []byte isn't comparable so it can only be a key of an interface type such
+// as Filter[any].
+type Filter[K comparable] struct {
+	f *Rsqf
+}
+
+// NewFilter returns a Filter for keys of type K described by c.
+func NewFilter[K comparable](c Config) (*Filter[K], error) {
+	f, err := NewWithConfig(c)
+	if err != nil {
+		return nil, err
+	}
+	return &Filter[K]{f: f}, nil
+}
+
+// Rsqf returns the underlying filter.
+func (t *Filter[K]) Rsqf() *Rsqf {
+	return t.f
+}
+
+// Items returns the number of keys stored in the filter.
+func (t *Filter[K]) Items() uint64 {
+	return t.f.Items()
+}
+
+// Add inserts k into the filter.
+func (t *Filter[K]) Add(k K) error {
+	x, err := t.hash(k)
+	if err != nil {
+		return err
+	}
+	return t.f.Insert(x)
+}
+
+// Has tests if k exists in the filter. Keys that can't be encoded are never
+// found.
+func (t *Filter[K]) Has(k K) bool {
+	x, err := t.hash(k)
+	if err != nil {
+		return false
+	}
+	return t.f.MayContainHash(x)
+}
+
+// Remove deletes one instance of k from the filter. It returns false if k was
+// not found.
+func (t *Filter[K]) Remove(k K) (bool, error) {
+	x, err := t.hash(k)
+	if err != nil {
+		return false, err
+	}
+	return t.f.Delete(x)
+}
+
+// hash returns the hash of k. Named types are handled by their kind, integer
+// kinds such as time.Duration are mixed like the integers they are even when
+// they implement an interface, other kinds are checked for the interfaces
+// first.
+func (t *Filter[K]) hash(k K) (uint64, error) {
+	switch v := any(k).(type) {
+	case string:
+		return t.f.Hash([]byte(v)), nil
+	case []byte:
+		return t.f.Hash(v), nil
+	case int:
+		return t.mixSigned(int64(v)), nil
+	case int8:
+		return t.mixSigned(int64(v)), nil
+	case int16:
+		return t.mixSigned(int64(v)), nil
+	case int32:
+		return t.mixSigned(int64(v)), nil
+	case int64:
+		return t.mixSigned(v), nil
+	case uint:
+		return t.mix(uint64(v)), nil
+	case uint8:
+		return t.mix(uint64(v)), nil
+	case uint16:
+		return t.mix(uint64(v)), nil
+	case uint32:
+		return t.mix(uint64(v)), nil
+	case uint64:
+		return t.mix(v), nil
+	case uintptr:
+		return t.mix(uint64(v)), nil
+	}
+
+	rv := reflect.ValueOf(k)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return t.mixSigned(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return t.mix(rv.Uint()), nil
+	}
+
+	switch v := any(k).(type) {
+	case encoding.BinaryMarshaler:
+		b, err := v.MarshalBinary()
+		if err != nil {
+			return 0, err
+		}
+		return t.f.Hash(b), nil
+	case fmt.Stringer:
+		return t.f.Hash([]byte(v.String())), nil
+	}
+
+	if rv.Kind() == reflect.String {
+		return t.f.Hash([]byte(rv.String())), nil
+	}
+	return 0, ErrUnsupportedKey
+}
+
+// mixSigned returns the hash of the signed integer v. The sign bit is flipped
+// so negative keys sort before positive keys with an ordered hasher.
+func (t *Filter[K]) mixSigned(v int64) uint64 {
+	return t.mix(uint64(v) ^ 1<<63)
+}
+
+// mix returns the hash of the integer v using the MurmurHash3 finalizer which
+// is a bijection so distinct keys never collide before the hash is split.
+func (t *Filter[K]) mix(v uint64) uint64 {
+	h := t.f.hasher
+	if h.ID() == Ordered {
+		var b [8]byte
+		binary.BigEndian.PutUint64(b[:], v)
+		return h.Sum64(b[:])
+	}
+	return murmurFmix(v ^ h.Seed())
+}
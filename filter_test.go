@@ -0,0 +1,147 @@
+//go:build go1.18
+// +build go1.18
+
+package rsqf_test
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	. "github.com/nfisher/rsqf"
+)
+
+type userID int64
+
+type point struct{ x, y int }
+
+func (p point) String() string {
+	return fmt.Sprintf("%d,%d", p.x, p.y)
+}
+
+type opaque struct{ a, b int }
+
+type colour int
+
+func (c colour) String() string {
+	return [...]string{"red", "green", "blue"}[c]
+}
+
+func Test_Filter_should_add_and_remove_integers(t *testing.T) {
+	t.Parallel()
+	f, err := NewFilter[userID](Config{N: 10000})
+	if err != nil {
+		t.Fatalf("want NewFilter() err = nil, got %v", err)
+	}
+
+	for id := userID(-500); id < 500; id++ {
+		err := f.Add(id)
+		if err != nil {
+			t.Fatalf("want Add(%v) err = nil, got %v", id, err)
+		}
+	}
+
+	for id := userID(-500); id < 500; id++ {
+		if !f.Has(id) {
+			t.Fatalf("want Has(%v) = true, got false", id)
+		}
+	}
+
+	if f.Items() != 1000 {
+		t.Errorf("want Items() = 1000, got %v", f.Items())
+	}
+
+	for id := userID(-500); id < 500; id++ {
+		ok, err := f.Remove(id)
+		if !ok || err != nil {
+			t.Fatalf("want Remove(%v) = true, nil, got %v, %v", id, ok, err)
+		}
+	}
+
+	err = f.Rsqf().Verify()
+	if err != nil {
+		t.Errorf("want Verify() = nil, got %v", err)
+	}
+}
+
+func Test_Filter_should_hash_keys_with_the_configured_hasher(t *testing.T) {
+	t.Parallel()
+	f, _ := NewFilter[string](Config{N: 1000, Hasher: NewXXHash64(7)})
+	f.Add("hello")
+
+	if !f.Rsqf().MayContainString("hello") {
+		t.Error("want MayContainString(hello) = true, got false")
+	}
+
+	if f.Has("world") {
+		t.Error("want Has(world) = false, got true")
+	}
+}
+
+func Test_Filter_should_encode_key_types(t *testing.T) {
+	t.Parallel()
+	f, _ := NewFilter[any](Config{N: 1000})
+	when := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	td := []struct {
+		key any
+		err error
+	}{
+		{"text", nil},
+		{[]byte("bytes"), nil},
+		{uint8(7), nil},
+		{when, nil},
+		{point{1, 2}, nil},
+		{opaque{1, 2}, ErrUnsupportedKey},
+	}
+
+	for i, tc := range td {
+		err := f.Add(tc.key)
+		if err != tc.err {
+			t.Errorf("[%v] want Add(%v) err = %v, got %v", i, tc.key, tc.err, err)
+		}
+
+		want := tc.err == nil
+		if f.Has(tc.key) != want {
+			t.Errorf("[%v] want Has(%v) = %v, got %v", i, tc.key, want, !want)
+		}
+	}
+
+	if !f.Rsqf().MayContainString("1,2") {
+		t.Error("want Stringer key to be hashed from String()")
+	}
+}
+
+func Test_Filter_should_mix_named_integers_with_methods(t *testing.T) {
+	t.Parallel()
+	td := []any{time.Duration(5), colour(2)}
+
+	for i, key := range td {
+		f, _ := NewFilter[any](Config{N: 1000})
+		f.Add(key)
+
+		v := reflect.ValueOf(key).Int()
+		if !f.Has(v) {
+			t.Errorf("[%v] want Has(int64(%v)) = true after Add(%v), got false", i, v, key)
+		}
+
+		if f.Rsqf().MayContainString(fmt.Sprint(key)) {
+			t.Errorf("[%v] want %q not to be hashed, got MayContainString() = true", i, fmt.Sprint(key))
+		}
+	}
+}
+
+func Benchmark_Filter_Has_int(b *testing.B) {
+	f, _ := NewFilter[int](Config{N: 1 << 20})
+	for i := 0; i < b.N; i++ {
+		f.Has(i)
+	}
+}
+
+func Benchmark_Filter_Has_string(b *testing.B) {
+	f, _ := NewFilter[string](Config{N: 1 << 20})
+	for i := 0; i < b.N; i++ {
+		f.Has("12345678")
+	}
+}
@@ -0,0 +1,223 @@
+package rsqf
+
+import "errors"
+
+// ErrInvalidGrowAt is returned when the load factor to grow at is not between
+// 0 and 1.
+var ErrInvalidGrowAt = errors.New("RSQF grow load factor must be between 0 and 1")
+
+// growStep is the number of entries moved into the larger filter by each
+// insert or delete while a filter is growing. The larger filter has twice the
+// slots so the migration finishes long before it fills.
+const growStep = 16
+
+// growth is an incremental migration of a filter into one with twice as many
+// slots. Fingerprints below boundary have been copied to next, those at or
+// above it are still read from the original filter. Inserts go to next so
+// both filters are checked for fingerprints that haven't been migrated.
+type growth struct {
+	next     *Rsqf
+	boundary uint64
+}
+
+// insertN inserts n instances of x into the larger filter. When x doesn't
+// fit, e.g. a cluster runs past its overflow slots, the larger filter is
+// replaced by one with twice its slots so the migration can continue.
+func (g *growth) insertN(x, n uint64) error {
+	err := g.next.InsertN(x, n)
+	if err != ErrFilterOverflow {
+		return err
+	}
+
+	next, err := g.next.Resize(float64(2 * g.next.homeSlots()))
+	if err != nil {
+		return ErrFilterOverflow
+	}
+	g.next = next
+
+	return g.next.InsertN(x, n)
+}
+
+// overLoad returns true if inserting into q would take its occupied slots past
+// the grow threshold.
+func (q *Rsqf) overLoad() bool {
+	return float64(q.slotsUsed()+1) > q.growAt*float64(q.homeSlots())
+}
+
+// slotsUsed returns the number of slots that belong to a run. Mapped filters
+// count them on first use so opening one doesn't read every block.
+func (q *Rsqf) slotsUsed() uint64 {
+	if q.usedStale {
+		q.used, q.usedStale = q.usedSlots(), false
+	}
+	return q.used
+}
+
+// usedSlots counts the slots that belong to a run. Every slot from the first
+// home slot of a cluster to its last runend is used.
+func (q *Rsqf) usedSlots() uint64 {
+	var used, open uint64
+	for bi := uint64(0); bi < q.blocks(); bi++ {
+		blk := q.block(bi)
+		if open == 0 && blk.Occupieds == 0 {
+			continue
+		}
+
+		for j := uint64(0); j < blockLen; j++ {
+			open += (blk.Occupieds >> j) & 0x1
+			if open > 0 {
+				used++
+			}
+			open -= (blk.Runends >> j) & 0x1
+		}
+	}
+	return used
+}
+
+// startGrow begins migrating q into a filter with one more quotient bit. The
+// threshold is cleared if the universe p has no remainder bits left to give
+// so q continues as a fixed size filter.
+func (q *Rsqf) startGrow() error {
+	next, err := q.resized(q.quotient + 1)
+	if err != nil {
+		q.growAt = 0
+		return err
+	}
+
+	q.grow = &growth{next: next}
+	return nil
+}
+
+// migrate copies up to limit entries from q into the larger filter and
+// replaces q with it once every entry has been copied. Duplicate fingerprints
+// are visited separately without counters so a step doesn't end until the
+// fingerprint changes, boundary always falls between two fingerprints.
+func (q *Rsqf) migrate(limit int) error {
+	g := q.grow
+	if g == nil {
+		return nil
+	}
+
+	it := q.plain().Iterator()
+	it.Seek(g.boundary)
+
+	var moved int
+	var last, copied uint64
+	for it.Next() {
+		x := it.Hash()
+		if x != last {
+			if moved >= limit {
+				g.boundary = x
+				return nil
+			}
+			copied = 0
+		}
+
+		err := g.insertN(x, it.Count())
+		if err != nil {
+			// x is migrated again as a whole by the next step.
+			g.next.DeleteN(x, copied)
+			g.boundary = x
+			return err
+		}
+		copied += it.Count()
+		moved++
+		last = x
+	}
+
+	next := g.next
+	next.growAt = q.growAt
+	next.items = q.items
+	*q = *next
+
+	return nil
+}
+
+// finishGrow completes any migration in progress so q can be used as a single
+// filter.
+func (q *Rsqf) finishGrow() error {
+	for q.grow != nil {
+		err := q.migrate(int(^uint(0) >> 1))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// plain returns a copy of q that shares its storage without the growth state
+// so it can be read and modified as a fixed size filter.
+func (q *Rsqf) plain() *Rsqf {
+	v := *q
+	v.growAt = 0
+	v.grow = nil
+	return &v
+}
+
+// growInsertN inserts n instances of x into a filter that grows. Once q is
+// over the threshold, or x doesn't fit, inserts go to the larger filter while
+// entries are migrated to it.
+func (q *Rsqf) growInsertN(x, n uint64) error {
+	return q.perInstance(x, n, q.growInsert)
+}
+
+// growInsert is growInsertN for a single instance or counter.
+func (q *Rsqf) growInsert(x, n uint64) error {
+	err := q.migrate(growStep)
+	if err != nil {
+		return err
+	}
+
+	if q.grow == nil && q.overLoad() {
+		q.startGrow()
+	}
+
+	if g := q.grow; g != nil {
+		err := g.insertN(x, n)
+		if err == nil {
+			q.items += n
+		}
+		return err
+	}
+
+	v := q.plain()
+	err = v.InsertN(x, n)
+	q.keep(v)
+	if err == ErrFilterOverflow && q.startGrow() == nil {
+		return q.growInsert(x, n)
+	}
+	return err
+}
+
+// growDeleteN removes up to n instances of x from a filter that grows.
+// Unmigrated instances are removed from q before those in the larger filter.
+func (q *Rsqf) growDeleteN(x, n uint64) (bool, error) {
+	// a migration that overflowed can continue once entries are deleted so
+	// it doesn't stop the delete.
+	q.migrate(growStep)
+
+	var found bool
+	var err error
+	if g := q.grow; g == nil || x&(q.qMask|q.rMask) >= g.boundary {
+		v := q.plain()
+		found, err = v.DeleteN(x, n)
+		n -= q.items - v.items
+		q.keep(v)
+		if err != nil || n == 0 || g == nil {
+			return found, err
+		}
+	}
+
+	next := q.grow.next
+	items := next.items
+	ok, err := next.DeleteN(x, n)
+	q.items -= items - next.items
+	return found || ok, err
+}
+
+// keep copies the state changed by an insert or delete on the plain copy v
+// back into q.
+func (q *Rsqf) keep(v *Rsqf) {
+	q.items = v.items
+	q.used = v.used
+}
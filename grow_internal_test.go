@@ -0,0 +1,205 @@
+package rsqf
+
+import (
+	"testing"
+	"unsafe"
+)
+
+// usedFilter returns a counting filter whose counters take 1, 2 and 4 slots
+// and where a delete has shifted a displaced run back towards its home slot.
+func usedFilter() *Rsqf {
+	f := NewCounting(1000)
+	f.InsertN(0x10<<9|5, 1)
+	f.InsertN(0x10<<9|6, 2)
+	f.InsertN(0x11<<9|7, 40)
+	f.InsertN(0x12<<9|8, 1)
+	f.Delete(0x10<<9 | 5)
+	return f
+}
+
+func Test_used_should_track_slots_in_runs(t *testing.T) {
+	t.Parallel()
+	f := usedFilter()
+
+	if f.used != 7 {
+		t.Errorf("want used = 7, got %v", f.used)
+	}
+
+	if f.usedSlots() != f.used {
+		t.Errorf("want usedSlots() = %v, got %v", f.used, f.usedSlots())
+	}
+}
+
+func Test_used_should_be_restored_when_decoded(t *testing.T) {
+	t.Parallel()
+	f := usedFilter()
+	b, _ := f.MarshalBinary()
+
+	var g Rsqf
+	err := g.UnmarshalBinary(b)
+	if err != nil {
+		t.Fatalf("want UnmarshalBinary() err = nil, got %v", err)
+	}
+
+	if g.slotsUsed() != f.used {
+		t.Errorf("want slotsUsed() = %v, got %v", f.used, g.slotsUsed())
+	}
+}
+
+func Test_used_should_be_counted_on_first_use_when_mapped(t *testing.T) {
+	t.Parallel()
+	f := usedFilter()
+	b, _ := f.MarshalBinary()
+
+	// the words keep the blocks 8 byte aligned.
+	words := make([]uint64, (len(b)+7)/8)
+	data := (*[1 << 30]byte)(unsafe.Pointer(&words[0]))[:len(b):len(b)]
+	copy(data, b)
+
+	m, err := mapFilter(data)
+	if err == ErrMisaligned {
+		t.Skip("the block layout of this platform can't be mapped")
+	}
+	if err != nil {
+		t.Fatalf("want mapFilter() err = nil, got %v", err)
+	}
+
+	if m.used != 0 || !m.usedStale {
+		t.Errorf("want used to be counted on first use, got %v, %v", m.used, m.usedStale)
+	}
+
+	if m.slotsUsed() != f.used {
+		t.Errorf("want slotsUsed() = %v, got %v", f.used, m.slotsUsed())
+	}
+}
+
+// crowdedGrowth returns a filter of config c migrating into the larger filter
+// where the last home slot of both holds so many copies of x that the
+// migration doesn't fit.
+func crowdedGrowth(t *testing.T, c Config, x uint64) *Rsqf {
+	f, _ := NewWithConfig(c)
+	for i := 0; i < 100; i++ {
+		err := f.Insert(x)
+		if err != nil {
+			t.Fatalf("[%v] want Insert(0x%X) err = nil, got %v", i, x, err)
+		}
+	}
+
+	err := f.startGrow()
+	if err != nil {
+		t.Fatalf("want startGrow() err = nil, got %v", err)
+	}
+
+	// inserts while growing go to the larger filter.
+	for i := 0; i < 60; i++ {
+		err := f.grow.next.Insert(x)
+		if err != nil {
+			t.Fatalf("[%v] want next.Insert(0x%X) err = nil, got %v", i, x, err)
+		}
+		f.items++
+	}
+	return f
+}
+
+func Test_migrate_should_regrow_when_the_larger_filter_overflows(t *testing.T) {
+	t.Parallel()
+	// q = 6 and the larger filter has 7 quotient bits, the last home slot of
+	// each has room for 129 entries.
+	x := uint64(63<<9 | 0x1FF)
+	f := crowdedGrowth(t, Config{N: 64}, x)
+
+	err := f.finishGrow()
+	if err != nil {
+		t.Fatalf("want finishGrow() err = nil, got %v", err)
+	}
+
+	if f.quotient != 8 {
+		t.Errorf("want quotient = 8, got %v", f.quotient)
+	}
+
+	if f.Count(x) != 160 {
+		t.Errorf("want Count(0x%X) = 160, got %v", x, f.Count(x))
+	}
+
+	err = f.Verify()
+	if err != nil {
+		t.Errorf("want Verify() = nil, got %v", err)
+	}
+}
+
+func Test_migrate_should_resume_after_deletes_when_the_universe_is_exhausted(t *testing.T) {
+	t.Parallel()
+	// p = 8 so the larger filter with r = 1 can't grow again.
+	x := uint64(63<<2 | 0x3)
+	f := crowdedGrowth(t, Config{N: 64, ErrRate: 0.25}, x)
+
+	it := f.Iterator()
+	if it.Err() != ErrFilterOverflow || it.Next() {
+		t.Errorf("want Iterator() Err() = ErrFilterOverflow and no fingerprints, got %v", it.Err())
+	}
+
+	// ranges are read from both filters.
+	if f.CountRange(0, x) != 160 || !f.MayContainRange(x, x) || f.MayContainRange(0, x-1) {
+		t.Errorf("want CountRange() = 160, got %v", f.CountRange(0, x))
+	}
+
+	for i := 0; i < 40; i++ {
+		ok, err := f.Delete(x)
+		if !ok || err != nil {
+			t.Fatalf("[%v] want Delete(0x%X) = true, nil, got %v, %v", i, x, ok, err)
+		}
+	}
+
+	err := f.finishGrow()
+	if err != nil {
+		t.Fatalf("want finishGrow() err = nil, got %v", err)
+	}
+
+	if f.Count(x) != 120 || f.Items() != 120 {
+		t.Errorf("want Count(0x%X) = Items() = 120, got %v, %v", x, f.Count(x), f.Items())
+	}
+
+	err = f.Verify()
+	if err != nil {
+		t.Errorf("want Verify() = nil, got %v", err)
+	}
+}
+
+func Test_migrate_should_answer_lookups_on_both_sides_of_the_boundary(t *testing.T) {
+	t.Parallel()
+	f, _ := NewWithConfig(Config{N: 1000, GrowAt: 0.9})
+	for x := uint64(0); x < 100; x++ {
+		f.Insert(x << 12)
+	}
+
+	err := f.startGrow()
+	if err != nil {
+		t.Fatalf("want startGrow() err = nil, got %v", err)
+	}
+
+	err = f.migrate(growStep)
+	if err != nil {
+		t.Fatalf("want migrate() err = nil, got %v", err)
+	}
+
+	if f.grow.boundary != growStep<<12 {
+		t.Errorf("want boundary = 0x%X, got 0x%X", growStep<<12, f.grow.boundary)
+	}
+
+	for x := uint64(0); x < 100; x++ {
+		if !f.MayContainHash(x << 12) {
+			t.Errorf("want MayContainHash(0x%X) = true, got false", x<<12)
+		}
+	}
+
+	// fingerprints below the boundary have been copied, those above it are
+	// only in the original filter.
+	f.plain().Delete(0)
+	if !f.MayContainHash(0) {
+		t.Error("want MayContainHash(0) = true, got false")
+	}
+	f.plain().Delete(growStep << 12)
+	if f.MayContainHash(growStep << 12) {
+		t.Errorf("want MayContainHash(0x%X) = false, got true", growStep<<12)
+	}
+}
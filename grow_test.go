@@ -0,0 +1,136 @@
+package rsqf_test
+
+import (
+	"math/rand"
+	"testing"
+
+	. "github.com/nfisher/rsqf"
+)
+
+func Test_NewWithConfig_should_reject_invalid_GrowAt(t *testing.T) {
+	t.Parallel()
+	td := []float64{-0.5, 1.5}
+
+	for _, at := range td {
+		_, err := NewWithConfig(Config{N: 1000, GrowAt: at})
+		if err != ErrInvalidGrowAt {
+			t.Errorf("GrowAt %v want err = ErrInvalidGrowAt, got %v", at, err)
+		}
+	}
+}
+
+func Test_GrowAt_should_grow_when_a_key_overflows_below_the_threshold(t *testing.T) {
+	t.Parallel()
+	f, err := NewWithConfig(Config{N: 1000, GrowAt: 0.99})
+	if err != nil {
+		t.Fatalf("want NewWithConfig() err = nil, got %v", err)
+	}
+	slots := len(f.Q)
+
+	// the last home slot and its 320 overflow slots take 321 copies, the
+	// rest go to the larger filter.
+	x := uint64(0x3FF<<9 | 0x1FF)
+	for i := 0; i < 400; i++ {
+		err := f.Insert(x)
+		if err != nil {
+			t.Fatalf("[%v] want Insert(0x%X) err = nil, got %v", i, x, err)
+		}
+	}
+
+	if f.Count(x) != 400 || f.Items() != 400 {
+		t.Errorf("want Count(0x%X) = Items() = 400, got %v, %v", x, f.Count(x), f.Items())
+	}
+
+	err = f.Verify()
+	if err != nil {
+		t.Errorf("want Verify() = nil, got %v", err)
+	}
+
+	if len(f.Q) <= slots {
+		t.Errorf("want len(Q) > %v, got %v", slots, len(f.Q))
+	}
+}
+
+func Test_GrowAt_should_delete_while_growing(t *testing.T) {
+	t.Parallel()
+	f, err := NewWithConfig(Config{N: 1000, Counting: true, GrowAt: 0.5})
+	if err != nil {
+		t.Fatalf("want NewWithConfig() err = nil, got %v", err)
+	}
+
+	slots := len(f.Q)
+
+	r := rand.New(rand.NewSource(23))
+	counts := make(map[uint64]uint64)
+	var keys []uint64
+	for i := 0; i < 6000; i++ {
+		// p = 19 so keys are their own fingerprint.
+		x := r.Uint64() & (1<<19 - 1)
+		if i%3 == 2 {
+			x = keys[r.Intn(len(keys))]
+			ok, err := f.DeleteN(x, 2)
+			if ok != (counts[x] > 0) || err != nil {
+				t.Fatalf("[%v] want DeleteN(0x%X, 2) = %v, nil, got %v, %v", i, x, counts[x] > 0, ok, err)
+			}
+			if counts[x] < 2 {
+				delete(counts, x)
+			} else {
+				counts[x] -= 2
+			}
+		} else {
+			err := f.InsertN(x, 3)
+			if err != nil {
+				t.Fatalf("[%v] want InsertN(0x%X, 3) err = nil, got %v", i, x, err)
+			}
+			counts[x] += 3
+			keys = append(keys, x)
+		}
+
+		// p = 19 so every key is its own fingerprint.
+		if c := f.Count(x); c != counts[x] {
+			t.Fatalf("[%v] want Count(0x%X) = %v, got %v", i, x, counts[x], c)
+		}
+	}
+
+	var items uint64
+	for _, c := range counts {
+		items += c
+	}
+	if f.Items() != items {
+		t.Errorf("want Items() = %v, got %v", items, f.Items())
+	}
+
+	if len(f.Q) <= slots {
+		t.Errorf("want len(Q) > %v, got %v", slots, len(f.Q))
+	}
+
+	err = f.Verify()
+	if err != nil {
+		t.Errorf("want Verify() = nil, got %v", err)
+	}
+}
+
+func Test_GrowAt_should_overflow_when_universe_is_exhausted(t *testing.T) {
+	t.Parallel()
+	f, err := NewWithConfig(Config{N: 64, ErrRate: 0.25, GrowAt: 0.9})
+	if err != nil {
+		t.Fatalf("want NewWithConfig() err = nil, got %v", err)
+	}
+
+	// p = 8 so the filter can grow once to 128 home slots with r = 1. The 4
+	// blocks hold 256 slots so fingerprints are repeated to fill them.
+	for x := uint64(0); x < 512; x++ {
+		err = f.Insert(x)
+		if err != nil {
+			break
+		}
+	}
+
+	if err != ErrFilterOverflow {
+		t.Errorf("want err = ErrFilterOverflow, got %v", err)
+	}
+
+	if f.Blocks() != 4 {
+		t.Errorf("want 4 blocks, got %v", f.Blocks())
+	}
+}
@@ -0,0 +1,193 @@
+package rsqf
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// HasherID identifies the algorithm used by a Hasher. It is recorded with a
+// filter so that hashes from different algorithms are never mixed.
+type HasherID uint8
+
+const (
+	// FNV1a is the 64-bit FNV-1a hash from the standard library.
+	FNV1a HasherID = iota + 1
+	// XXHash64 is the 64-bit xxHash.
+	XXHash64
+	// Murmur3 is the lower 64-bits of MurmurHash3 x64 128.
+	Murmur3
+	// Ordered preserves the order of keys, see NewOrdered.
+	Ordered
+)
+
+// Hasher is a seeded 64-bit hash function used to fingerprint keys.
+type Hasher interface {
+	// Sum64 returns the hash of b.
+	Sum64(b []byte) uint64
+	// ID returns the algorithm used by the hasher.
+	ID() HasherID
+	// Seed returns the seed the hasher was created with.
+	Seed() uint64
+}
+
+// ErrUnknownHasher is returned when a HasherID has no implementation.
+var ErrUnknownHasher = errors.New("RSQF unknown hasher")
+
+// ErrInvalidShift is returned when the shift of an ordered hasher would drop
+// every bit of a key or leave hashes wider than the filter's universe.
+var ErrInvalidShift = errors.New("RSQF ordered hasher shift must be less than the key bits and keep hashes within p bits")
+
+// ErrInvalidKeyBits is returned when the keys of an ordered hasher are wider
+// than 64 bits.
+var ErrInvalidKeyBits = errors.New("RSQF ordered key bits must be between 1 and 64")
+
+// ErrOrderedHasher is returned when a Config sets both Ordered and Hasher.
+var ErrOrderedHasher = errors.New("RSQF ordered config can't have a hasher")
+
+// NewHasher returns the hasher for id initialised with seed. Ordered has no
+// seed, the low 8 bits of seed are its shift and the bits above them its key
+// bits, 0 for 64.
+func NewHasher(id HasherID, seed uint64) (Hasher, error) {
+	switch id {
+	case FNV1a:
+		return NewFNV(seed), nil
+	case XXHash64:
+		return NewXXHash64(seed), nil
+	case Murmur3:
+		return NewMurmur3(seed), nil
+	case Ordered:
+		bits := seed >> 8
+		if bits == 0 {
+			bits = 64
+		}
+		if bits > 64 {
+			return nil, ErrInvalidKeyBits
+		}
+		if seed&0xFF >= bits {
+			return nil, ErrInvalidShift
+		}
+		return NewOrderedBits(bits, seed&0xFF), nil
+	}
+	return nil, ErrUnknownHasher
+}
+
+// hasherParam returns the value NewHasher needs to recreate h, the shift and
+// key bits of an ordered hasher and the seed of the others.
+func hasherParam(h Hasher) uint64 {
+	if o, ok := h.(*orderedHasher); ok {
+		return o.bits%64<<8 | o.shift
+	}
+	return h.Seed()
+}
+
+// checkOrdered returns an error if h is an ordered hasher whose hashes can be
+// wider than a p-bit universe. The bits above p are dropped when a hash is
+// split so a range over the full hashes would miss inserted keys.
+func checkOrdered(h Hasher, p uint64) error {
+	o, ok := h.(*orderedHasher)
+	if !ok {
+		return nil
+	}
+
+	if o.bits == 0 || o.bits > 64 {
+		return ErrInvalidKeyBits
+	}
+	if o.shift >= o.bits || o.bits-o.shift > p {
+		return ErrInvalidShift
+	}
+	return nil
+}
+
+// sameHasher returns true if a and b produce the same hashes.
+func sameHasher(a, b Hasher) bool {
+	return a.ID() == b.ID() && hasherParam(a) == hasherParam(b)
+}
+
+const (
+	fnvOffset64 uint64 = 14695981039346656037
+	fnvPrime64  uint64 = 1099511628211
+)
+
+type fnvHasher struct {
+	seed  uint64
+	basis uint64
+}
+
+// NewFNV returns a 64-bit FNV-1a hasher. A non-zero seed is hashed before the
+// input, a seed of 0 is equivalent to hash/fnv.
+func NewFNV(seed uint64) Hasher {
+	h := &fnvHasher{seed: seed, basis: fnvOffset64}
+	if seed != 0 {
+		var b [8]byte
+		binary.LittleEndian.PutUint64(b[:], seed)
+		h.basis = fnv1a(h.basis, b[:])
+	}
+	return h
+}
+
+func fnv1a(h uint64, b []byte) uint64 {
+	for _, c := range b {
+		h ^= uint64(c)
+		h *= fnvPrime64
+	}
+	return h
+}
+
+func (h *fnvHasher) Sum64(b []byte) uint64 {
+	return fnv1a(h.basis, b)
+}
+
+func (h *fnvHasher) ID() HasherID {
+	return FNV1a
+}
+
+func (h *fnvHasher) Seed() uint64 {
+	return h.seed
+}
+
+type orderedHasher struct {
+	bits  uint64 // width of the keys, wider keys are saturated.
+	shift uint64
+}
+
+// NewOrdered returns an order-preserving hasher for range queries. The first 8
+// bytes of a key are read as a big-endian integer, shorter keys are padded
+// with zeros, and shifted right by shift. The hasher has no seed.
+//
+// The filter stores the lowest p bits of a hash so the shift must be at least
+// 64 - p, NewWithConfig rejects an ordered hasher whose hashes would be
+// truncated. Keys that share their leading p bits have the same fingerprint.
+func NewOrdered(shift uint64) Hasher {
+	return NewOrderedBits(64, shift)
+}
+
+// NewOrderedBits returns an ordered hasher for keys below 2^bits, such as unix
+// timestamps which fit in 32 bits. Keys are read like NewOrdered, larger keys
+// are saturated to 2^bits - 1 so their order is kept, and shifted right by
+// shift which must be at least bits - p. Config.Ordered selects the shift.
+func NewOrderedBits(bits, shift uint64) Hasher {
+	return &orderedHasher{bits: bits, shift: shift}
+}
+
+func (h *orderedHasher) Sum64(b []byte) uint64 {
+	var buf [8]byte
+	copy(buf[:], b)
+	v := binary.BigEndian.Uint64(buf[:])
+	if max := ^uint64(0) >> (64 - h.bits); v > max {
+		v = max
+	}
+	return v >> h.shift
+}
+
+func (h *orderedHasher) ID() HasherID {
+	return Ordered
+}
+
+func (h *orderedHasher) Seed() uint64 {
+	return 0
+}
+
+// rotl rotates x left by r bits.
+func rotl(x uint64, r uint) uint64 {
+	return (x << r) | (x >> (64 - r))
+}
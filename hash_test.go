@@ -0,0 +1,170 @@
+package rsqf_test
+
+import (
+	"hash/fnv"
+	"testing"
+
+	. "github.com/nfisher/rsqf"
+)
+
+func Test_XXHash64_reference_vectors(t *testing.T) {
+	t.Parallel()
+	td := []struct {
+		input    string
+		expected uint64
+	}{
+		{"", 0xEF46DB3751D8E999},
+		{"abc", 0x44BC2CF5AD770999},
+		{"Nobody inspects the spammish repetition", 0xFBCEA83C8A378BF1},
+	}
+
+	h := NewXXHash64(0)
+	for i, v := range td {
+		actual := h.Sum64([]byte(v.input))
+		if v.expected != actual {
+			t.Errorf("[%v] want Sum64(%q) = 0x%X, got 0x%X", i, v.input, v.expected, actual)
+		}
+	}
+}
+
+func Test_Murmur3_reference_vectors(t *testing.T) {
+	t.Parallel()
+	td := []struct {
+		input    string
+		expected uint64
+	}{
+		{"", 0x0},
+		{"hello", 0xCBD8A7B341BD9B02},
+	}
+
+	h := NewMurmur3(0)
+	for i, v := range td {
+		actual := h.Sum64([]byte(v.input))
+		if v.expected != actual {
+			t.Errorf("[%v] want Sum64(%q) = 0x%X, got 0x%X", i, v.input, v.expected, actual)
+		}
+	}
+}
+
+func Test_FNV_zero_seed_should_match_standard_library(t *testing.T) {
+	t.Parallel()
+	td := []string{"", "a", "Hello world", "Nobody inspects the spammish repetition"}
+
+	h := NewFNV(0)
+	for i, v := range td {
+		std := fnv.New64a()
+		std.Write([]byte(v))
+		if std.Sum64() != h.Sum64([]byte(v)) {
+			t.Errorf("[%v] want Sum64(%q) = 0x%X, got 0x%X", i, v, std.Sum64(), h.Sum64([]byte(v)))
+		}
+	}
+}
+
+func Test_Hasher_seed_should_change_sum(t *testing.T) {
+	t.Parallel()
+	td := []HasherID{FNV1a, XXHash64, Murmur3}
+	// 40 bytes covers the block and tail paths of each hash.
+	input := []byte("Nobody inspects the spammish repetition!")
+
+	for i, id := range td {
+		h0, err := NewHasher(id, 0)
+		if err != nil {
+			t.Fatalf("[%v] want NewHasher(%v, 0) err = nil, got %v", i, id, err)
+		}
+		h1, _ := NewHasher(id, 1)
+		if h0.Sum64(input) == h1.Sum64(input) {
+			t.Errorf("[%v] want seeds 0 and 1 to differ, got 0x%X", i, h0.Sum64(input))
+		}
+		if id != h1.ID() || 1 != h1.Seed() {
+			t.Errorf("[%v] want ID, Seed = %v, 1, got %v, %v", i, id, h1.ID(), h1.Seed())
+		}
+	}
+}
+
+func Test_NewHasher_ordered_seed_should_be_the_shift(t *testing.T) {
+	t.Parallel()
+	td := []uint64{0, 1, 26, 63}
+	input := []byte{0x80}
+
+	for i, shift := range td {
+		h, err := NewHasher(Ordered, shift)
+		if err != nil {
+			t.Fatalf("[%v] want NewHasher(Ordered, %v) err = nil, got %v", i, shift, err)
+		}
+		if 1<<(63-shift) != h.Sum64(input) || 0 != h.Seed() {
+			t.Errorf("[%v] want Sum64 = 0x%X and no seed, got 0x%X, %v", i, uint64(1)<<(63-shift), h.Sum64(input), h.Seed())
+		}
+	}
+
+	// the bits above the shift are the key bits.
+	h, _ := NewHasher(Ordered, 32<<8|6)
+	if 1<<25 != h.Sum64([]byte{0, 0, 0, 0, 0x80}) || 1<<26-1 != h.Sum64([]byte{0x80}) {
+		t.Errorf("want 32 bit keys shifted by 6, got 0x%X, 0x%X", h.Sum64([]byte{0, 0, 0, 0, 0x80}), h.Sum64([]byte{0x80}))
+	}
+
+	invalid := []struct {
+		seed     uint64
+		expected error
+	}{
+		{64, ErrInvalidShift},
+		{32<<8 | 32, ErrInvalidShift},
+		{65 << 8, ErrInvalidKeyBits},
+	}
+
+	for i, v := range invalid {
+		_, err := NewHasher(Ordered, v.seed)
+		if err != v.expected {
+			t.Errorf("[%v] want NewHasher(Ordered, 0x%X) err = %v, got %v", i, v.seed, v.expected, err)
+		}
+	}
+}
+
+func Test_NewHasher_unknown_id(t *testing.T) {
+	t.Parallel()
+	_, err := NewHasher(0, 0)
+	if err != ErrUnknownHasher {
+		t.Errorf("want NewHasher(0, 0) err = ErrUnknownHasher, got %v", err)
+	}
+}
+
+func Test_NewWithConfig_should_record_hasher(t *testing.T) {
+	t.Parallel()
+	h := NewXXHash64(42)
+	f, err := NewWithConfig(Config{N: 10000, Hasher: h})
+	if err != nil {
+		t.Fatalf("want NewWithConfig() err = nil, got %v", err)
+	}
+
+	err = f.InsertString("Hello world")
+	if err != nil {
+		t.Fatalf("want InsertString() err = nil, got %v", err)
+	}
+
+	if f.Hash([]byte("Hello world")) != h.Sum64([]byte("Hello world")) {
+		t.Error("want Hash() to use the configured hasher")
+	}
+
+	if !f.MayContainString("Hello world") {
+		t.Error("want MayContainString(\"Hello world\") = true, got false")
+	}
+
+	if err := f.CheckHasher(NewXXHash64(42)); err != nil {
+		t.Errorf("want CheckHasher(xxhash 42) = nil, got %v", err)
+	}
+
+	if err := f.CheckHasher(NewXXHash64(7)); err != ErrHasherMismatch {
+		t.Errorf("want CheckHasher(xxhash 7) = ErrHasherMismatch, got %v", err)
+	}
+
+	if err := f.CheckHasher(NewFNV(42)); err != ErrHasherMismatch {
+		t.Errorf("want CheckHasher(fnv 42) = ErrHasherMismatch, got %v", err)
+	}
+}
+
+func Test_NewWithConfig_counting_requires_two_remainder_bits(t *testing.T) {
+	t.Parallel()
+	_, err := NewWithConfig(Config{N: 1000, ErrRate: 0.5, Counting: true})
+	if err != ErrInvalidRate {
+		t.Errorf("want NewWithConfig() err = ErrInvalidRate, got %v", err)
+	}
+}
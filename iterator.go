@@ -0,0 +1,99 @@
+package rsqf
+
+// Iterator walks the fingerprints stored in a filter in increasing order. The
+// filter must not be modified while it is being iterated.
+//
+//	it := f.Iterator()
+//	for it.Next() {
+//		fmt.Println(it.Hash(), it.Count())
+//	}
+type Iterator struct {
+	c   cursor
+	x   uint64
+	n   uint64
+	err error
+
+	// peeked is true when x and n hold an entry read by Seek that hasn't been
+	// returned by Next.
+	peeked bool
+}
+
+// Iterator returns an iterator positioned before the first fingerprint. A
+// filter that is growing completes its migration first, if it can't the
+// iterator is empty and Err returns the reason.
+func (q *Rsqf) Iterator() *Iterator {
+	err := q.finishGrow()
+	return &Iterator{c: cursor{q: q}, err: err}
+}
+
+// Err returns the error that stopped a growing filter from completing its
+// migration, nil if the iterator visits every fingerprint.
+func (it *Iterator) Err() error {
+	return it.err
+}
+
+// Next advances to the next fingerprint. It returns false once every
+// fingerprint has been visited.
+func (it *Iterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+
+	if it.peeked {
+		it.peeked = false
+		return true
+	}
+
+	var ok bool
+	it.x, it.n, ok = it.c.next()
+	return ok
+}
+
+// Hash returns the current fingerprint, h0<<r | h1. It is the lowest p bits of
+// the hashes inserted into the filter.
+func (it *Iterator) Hash() uint64 {
+	return it.x
+}
+
+// Count returns the number of instances of the current fingerprint. A filter
+// that isn't counting returns each instance separately with a count of 1.
+func (it *Iterator) Count() uint64 {
+	return it.n
+}
+
+// Seek positions the iterator so that Next advances to the first fingerprint
+// greater than or equal to the fingerprint of the hash x.
+func (it *Iterator) Seek(x uint64) {
+	if it.err != nil {
+		return
+	}
+
+	q := it.c.q
+	target := x & (q.qMask | q.rMask)
+	h0, _ := q.split(target)
+
+	// the run for h0, or the next home slot, starts after the run of the last
+	// home slot before h0.
+	var s uint64
+	if h0 > 0 {
+		end, ok := q.lastEnd(h0 - 1)
+		if ok {
+			s = end + 1
+		}
+	}
+
+	it.c = cursor{q: q, from: h0, s: s}
+	it.peeked = false
+	for {
+		var ok bool
+		it.x, it.n, ok = it.c.next()
+		if !ok {
+			return
+		}
+
+		if it.x >= target {
+			it.peeked = true
+			return
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package rsqf_test
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+
+	. "github.com/nfisher/rsqf"
+)
+
+func Test_Iterator_should_visit_runs_in_the_overflow_slots(t *testing.T) {
+	t.Parallel()
+	// q = 10 and r = 9, the runs of the last two home slots take 200 slots so
+	// the cluster spills past the last home slot.
+	f := New(1000)
+	var expected []uint64
+	for i := uint64(0); i < 100; i++ {
+		expected = append(expected, 0x3FE<<9|i, 0x3FF<<9|i)
+	}
+	for i := len(expected) - 1; i >= 0; i-- {
+		err := f.Insert(expected[i])
+		if err != nil {
+			t.Fatalf("want Insert(0x%X) err = nil, got %v", expected[i], err)
+		}
+	}
+	sort.Slice(expected, func(i, j int) bool { return expected[i] < expected[j] })
+
+	var actual []uint64
+	it := f.Iterator()
+	for it.Next() {
+		actual = append(actual, it.Hash())
+	}
+
+	if len(expected) != len(actual) {
+		t.Fatalf("want %v fingerprints, got %v", len(expected), len(actual))
+	}
+	for i := range expected {
+		if expected[i] != actual[i] {
+			t.Fatalf("[%v] want Hash() = 0x%X, got 0x%X", i, expected[i], actual[i])
+		}
+	}
+
+	// the end of the run for the last home slot is in an overflow slot.
+	x := uint64(0x3FF<<9 | 80)
+	it.Seek(x)
+	if !it.Next() || it.Hash() != x {
+		t.Errorf("want Seek(0x%X) Hash() = 0x%X, got 0x%X", x, x, it.Hash())
+	}
+}
+
+func Test_Iterator_should_return_counters_once(t *testing.T) {
+	t.Parallel()
+	f := NewCounting(1000)
+	// a count of 300 takes more than one slot.
+	td := [][]uint64{
+		// hash, count
+		{0x0005, 3},
+		{0x0007, 1},
+		{0x0201, 300},
+	}
+	for _, v := range td {
+		f.InsertN(v[0], v[1])
+	}
+
+	it := f.Iterator()
+	for i, v := range td {
+		if !it.Next() || it.Hash() != v[0] || it.Count() != v[1] {
+			t.Errorf("[%v] want 0x%X x %v, got 0x%X x %v", i, v[0], v[1], it.Hash(), it.Count())
+		}
+	}
+
+	if it.Next() {
+		t.Errorf("want Next() = false after the last fingerprint, got 0x%X", it.Hash())
+	}
+}
+
+func Test_Iterator_should_return_each_instance_without_counters(t *testing.T) {
+	t.Parallel()
+	f := New(1000)
+	f.Insert(0x0005)
+	f.Insert(0x0005)
+
+	it := f.Iterator()
+	for i := 0; i < 2; i++ {
+		if !it.Next() || it.Hash() != 0x0005 || it.Count() != 1 {
+			t.Errorf("[%v] want 0x5 x 1, got 0x%X x %v", i, it.Hash(), it.Count())
+		}
+	}
+
+	if it.Next() {
+		t.Errorf("want Next() = false after the last instance, got 0x%X", it.Hash())
+	}
+}
+
+func Test_Iterator_should_drop_bits_above_the_fingerprint(t *testing.T) {
+	t.Parallel()
+	// p = 19, the top bits are ignored by the filter.
+	f := New(1000)
+	f.Insert(1<<40 | 0x0201)
+
+	it := f.Iterator()
+	if !it.Next() || it.Hash() != 0x0201 {
+		t.Errorf("want Hash() = 0x201, got 0x%X", it.Hash())
+	}
+}
+
+func Test_Iterator_Seek(t *testing.T) {
+	t.Parallel()
+	f := New(10000)
+	r := rand.New(rand.NewSource(37))
+
+	// p = 23 so keys are their own fingerprint.
+	var inserted []uint64
+	for i := 0; i < 2000; i++ {
+		x := r.Uint64() & (1<<23 - 1)
+		f.Insert(x)
+		inserted = append(inserted, x)
+	}
+	sort.Slice(inserted, func(i, j int) bool { return inserted[i] < inserted[j] })
+
+	targets := []uint64{0, inserted[0], inserted[1000], inserted[1000] + 1, inserted[len(inserted)-1]}
+	for i := 0; i < 100; i++ {
+		targets = append(targets, r.Uint64()&(1<<23-1))
+	}
+
+	it := f.Iterator()
+	for _, target := range targets {
+		i := sort.Search(len(inserted), func(i int) bool { return inserted[i] >= target })
+
+		it.Seek(target)
+		if !it.Next() {
+			t.Fatalf("want Seek(0x%X) Next() = true, got false", target)
+		}
+
+		if inserted[i] != it.Hash() {
+			t.Fatalf("want Seek(0x%X) Hash() = 0x%X, got 0x%X", target, inserted[i], it.Hash())
+		}
+	}
+
+	it.Seek(inserted[len(inserted)-1] + 1)
+	if it.Next() {
+		t.Errorf("want Next() = false after the last fingerprint, got 0x%X", it.Hash())
+	}
+}
+
+func Test_Iterator_Seek_displaced_run(t *testing.T) {
+	t.Parallel()
+	f := New(100000)
+	// h0 = 0 fills slots 0 to 2 and pushes the run for h0 = 1 to slot 3.
+	td := []uint64{0x0001, 0x0003, 0x0005, 0x0201, 0x0207}
+	for _, x := range td {
+		f.Insert(x)
+	}
+
+	seeks := [][]uint64{
+		// seek, expected
+		{0x0000, 0x0001},
+		{0x0002, 0x0003},
+		{0x0006, 0x0201},
+		{0x0200, 0x0201},
+		{0x0202, 0x0207},
+	}
+
+	it := f.Iterator()
+	for i, v := range seeks {
+		it.Seek(v[0])
+		if !it.Next() || v[1] != it.Hash() {
+			t.Errorf("[%v] want Seek(0x%X) Hash() = 0x%X, got 0x%X", i, v[0], v[1], it.Hash())
+		}
+	}
+}
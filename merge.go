@@ -0,0 +1,61 @@
+package rsqf
+
+import "errors"
+
+// ErrMergeMismatch is returned when filters with a different universe p,
+// remainder size or counting mode are merged.
+var ErrMergeMismatch = errors.New("RSQF merge requires the same p, r and mode")
+
+// Merge returns a new filter with the entries of a and b. Both filters are
+// walked in fingerprint order so the merge is linear in the number of slots,
+// counts for the same fingerprint are added together in counting mode.
+func Merge(a, b *Rsqf) (*Rsqf, error) {
+	if a.p != b.p || a.remainder != b.remainder || a.counting != b.counting {
+		return nil, ErrMergeMismatch
+	}
+
+	if !sameHasher(a.hasher, b.hasher) {
+		return nil, ErrHasherMismatch
+	}
+
+	for _, f := range []*Rsqf{a, b} {
+		err := f.finishGrow()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	filter := a.empty()
+	w := appender{q: filter}
+
+	ca, cb := cursor{q: a}, cursor{q: b}
+	xa, na, oka := ca.next()
+	xb, nb, okb := cb.next()
+	for oka || okb {
+		var x, n uint64
+		switch {
+		case oka && (!okb || xa < xb):
+			x, n = xa, na
+			xa, na, oka = ca.next()
+		case okb && (!oka || xb < xa):
+			x, n = xb, nb
+			xb, nb, okb = cb.next()
+		default:
+			x, n = xa, na+nb
+			xa, na, oka = ca.next()
+			xb, nb, okb = cb.next()
+		}
+
+		err := w.add(x, n)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := w.finish()
+	if err != nil {
+		return nil, err
+	}
+
+	return filter, nil
+}
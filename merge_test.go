@@ -0,0 +1,104 @@
+package rsqf_test
+
+import (
+	"math/rand"
+	"testing"
+
+	. "github.com/nfisher/rsqf"
+)
+
+func Test_Merge_should_contain_keys_from_both_filters(t *testing.T) {
+	t.Parallel()
+	a := New(10000)
+	b := New(10000)
+
+	r := rand.New(rand.NewSource(5))
+	inserted := make([]uint64, 4000)
+	for i := range inserted {
+		inserted[i] = r.Uint64()
+		f := a
+		if i%2 == 1 {
+			f = b
+		}
+		err := f.Insert(inserted[i])
+		if err != nil {
+			t.Fatalf("[%v] want Insert(0x%X) err = nil, got %v", i, inserted[i], err)
+		}
+	}
+
+	m, err := Merge(a, b)
+	if err != nil {
+		t.Fatalf("want Merge() err = nil, got %v", err)
+	}
+
+	for i, x := range inserted {
+		if !m.MayContainHash(x) {
+			t.Fatalf("[%v] want MayContainHash(0x%X) = true, got false", i, x)
+		}
+	}
+
+	// every instance is kept so each key can be deleted once.
+	for i, x := range inserted {
+		ok, err := m.Delete(x)
+		if !ok || err != nil {
+			t.Fatalf("[%v] want Delete(0x%X) = true, nil, got %v, %v", i, x, ok, err)
+		}
+	}
+}
+
+func Test_Merge_should_add_counts(t *testing.T) {
+	t.Parallel()
+	a := NewCounting(1000)
+	b := NewCounting(1000)
+	td := []struct {
+		x      uint64
+		a      uint64
+		b      uint64
+		expect uint64
+	}{
+		{0x0205, 1, 0, 1},
+		{0x0001, 2, 3, 5},
+		{0x0000, 0, 7, 7},
+		{0x1FFFF, 600, 600, 1200},
+	}
+
+	for _, v := range td {
+		a.InsertN(v.x, v.a)
+		b.InsertN(v.x, v.b)
+	}
+
+	m, err := Merge(a, b)
+	if err != nil {
+		t.Fatalf("want Merge() err = nil, got %v", err)
+	}
+
+	for i, v := range td {
+		if v.expect != m.Count(v.x) {
+			t.Errorf("[%v] want Count(0x%X) = %v, got %v", i, v.x, v.expect, m.Count(v.x))
+		}
+	}
+}
+
+func Test_Merge_mismatched_filters(t *testing.T) {
+	t.Parallel()
+	xx, _ := NewWithConfig(Config{N: 1000, Hasher: NewXXHash64(0)})
+	rate, _ := NewWithRate(1000, 1.0/1024.0)
+	td := []struct {
+		b        *Rsqf
+		expected error
+	}{
+		{New(100000), ErrMergeMismatch},
+		{rate, ErrMergeMismatch},
+		{NewCounting(1000), ErrMergeMismatch},
+		{xx, ErrHasherMismatch},
+		{New(1000), nil},
+	}
+
+	a := New(1000)
+	for i, v := range td {
+		_, err := Merge(a, v.b)
+		if v.expected != err {
+			t.Errorf("[%v] want Merge() err = %v, got %v", i, v.expected, err)
+		}
+	}
+}
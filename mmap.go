@@ -0,0 +1,128 @@
+package rsqf
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"reflect"
+	"unsafe"
+)
+
+// ErrReadOnly is returned when modifying a memory-mapped filter. Put and Put2
+// have no error to return and panic with it instead.
+var ErrReadOnly = errors.New("RSQF filter is read-only")
+
+// ErrMisaligned is returned when a mapped filter can't be used in place
+// because its layout doesn't match this platform.
+var ErrMisaligned = errors.New("RSQF mapped data is misaligned")
+
+// hostLittleEndian is true if the platform stores words in little-endian order
+// which the binary format uses.
+var hostLittleEndian = func() bool {
+	x := uint16(1)
+	return *(*byte)(unsafe.Pointer(&x)) == 1
+}()
+
+// mapFilter returns a read-only filter backed by data which holds a filter in
+// the binary format. Q points into data without copying. The checksums of the
+// ranges aren't read so opening a filter doesn't touch every page, see
+// VerifyChecksums. Versions before 4 keep the remainders apart from their
+// blocks so they return ErrUnsupportedVersion.
+func mapFilter(data []byte) (*Rsqf, error) {
+	if len(data) < headerLen {
+		return nil, ErrInvalidFormat
+	}
+
+	var h header
+	err := h.decode(data[:headerLen])
+	if err != nil {
+		return nil, err
+	}
+
+	hasher, err := NewHasher(h.hasherID, h.seed)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkOrdered(hasher, uint64(h.p))
+	if err != nil {
+		return nil, err
+	}
+
+	if h.version < 4 {
+		return nil, ErrUnsupportedVersion
+	}
+
+	words := h.blocks * (blockMeta + uint64(h.r))
+	if uint64(len(data)) != headerLen+words*8+h.ranges()*4 {
+		return nil, ErrInvalidFormat
+	}
+
+	if !hostLittleEndian || uintptr(unsafe.Pointer(&data[headerLen]))%unsafe.Alignof(uint64(0)) != 0 {
+		return nil, ErrMisaligned
+	}
+
+	filter := sizedRsqf(uint64(h.p), uint64(h.r))
+	filter.Q = wordsOf(data[headerLen:], int(words))
+	filter.counting = h.flags&flagCounting != 0
+	filter.hasher = hasher
+	filter.items = h.items
+	filter.usedStale = true
+	filter.readOnly = true
+	filter.mapped = data
+
+	return filter, nil
+}
+
+// VerifyChecksums reads every block of a filter opened with OpenMapped and
+// returns ErrChecksum if a range doesn't match its checksum.
+// OpenMapped only checks the header so a large filter opens without reading
+// it from disk. Other filters were checked by ReadFrom and return nil.
+func (q *Rsqf) VerifyChecksums() error {
+	if q.mapped == nil {
+		return nil
+	}
+
+	var h header
+	h.decode(q.mapped[:headerLen])
+
+	blocksLen := uint64(len(q.Q)) * 8
+	blocks := q.mapped[headerLen : headerLen+blocksLen]
+	crcs := q.mapped[headerLen+blocksLen:]
+	per := h.perRange() * (blockMeta + uint64(h.r)) * 8
+	for i := uint64(0); i < h.ranges(); i++ {
+		b := blocks[i*per:]
+		if uint64(len(b)) > per {
+			b = b[:per]
+		}
+
+		if crc32.Checksum(b, castagnoli) != binary.LittleEndian.Uint32(crcs[i*4:]) {
+			return ErrChecksum
+		}
+	}
+	return nil
+}
+
+// wordsOf returns n words that start at the first byte of b.
+func wordsOf(b []byte, n int) []uint64 {
+	var s []uint64
+	h := (*reflect.SliceHeader)(unsafe.Pointer(&s))
+	h.Data = uintptr(unsafe.Pointer(&b[0]))
+	h.Len = n
+	h.Cap = n
+	return s
+}
+
+// Close releases the memory mapping of a filter opened with OpenMapped. The
+// filter can't be used after it is closed. It does nothing for other filters.
+func (q *Rsqf) Close() error {
+	if q.unmap == nil {
+		return nil
+	}
+
+	err := q.unmap()
+	q.unmap = nil
+	q.mapped = nil
+	q.Q = nil
+	return err
+}
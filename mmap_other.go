@@ -0,0 +1,14 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package rsqf
+
+import "errors"
+
+// ErrMmapUnsupported is returned by OpenMapped on platforms without mmap.
+var ErrMmapUnsupported = errors.New("RSQF memory mapping is not supported")
+
+// OpenMapped is not supported on this platform, use ReadFrom instead.
+func OpenMapped(path string) (*Rsqf, error) {
+	return nil, ErrMmapUnsupported
+}
@@ -0,0 +1,49 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package rsqf
+
+import (
+	"os"
+	"syscall"
+)
+
+// OpenMapped memory-maps the filter stored in the binary format at path. The
+// blocks are used in place so lookups can be served without reading the file
+// into memory. The filter is read-only, mutating calls return ErrReadOnly. Only
+// the header is checked, VerifyChecksums checks the rest. Files written before
+// format version 4 return ErrUnsupportedVersion, read them with ReadFrom.
+// Close releases the mapping.
+func OpenMapped(path string) (*Rsqf, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	if fi.Size() < headerLen || int64(int(fi.Size())) != fi.Size() {
+		return nil, ErrInvalidFormat
+	}
+
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(fi.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, err
+	}
+
+	filter, err := mapFilter(data)
+	if err != nil {
+		syscall.Munmap(data)
+		return nil, err
+	}
+
+	filter.unmap = func() error {
+		return syscall.Munmap(data)
+	}
+
+	return filter, nil
+}
@@ -0,0 +1,155 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package rsqf_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/nfisher/rsqf"
+)
+
+func writeFilter(t *testing.T, dir string, f *Rsqf) string {
+	b, err := f.MarshalBinary()
+	if err != nil {
+		t.Fatalf("want MarshalBinary() err = nil, got %v", err)
+	}
+
+	path := filepath.Join(dir, "filter.rsqf")
+	err = ioutil.WriteFile(path, b, 0644)
+	if err != nil {
+		t.Fatalf("want WriteFile() err = nil, got %v", err)
+	}
+	return path
+}
+
+// openMapped opens the filter at path with OpenMapped. The test is skipped on
+// platforms where the block layout doesn't match the binary format, such as
+// 386 where a block is 20 bytes.
+func openMapped(t *testing.T, path string) *Rsqf {
+	t.Helper()
+	m, err := OpenMapped(path)
+	if err == ErrMisaligned {
+		t.Skip("the block layout of this platform can't be mapped")
+	}
+	if err != nil {
+		t.Fatalf("want OpenMapped() err = nil, got %v", err)
+	}
+	return m
+}
+
+func Test_OpenMapped_should_serve_lookups(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "rsqf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	f := New(100000)
+	td := []string{"Hello world", "hello world", "", "rank", "select"}
+	for _, v := range td[:3] {
+		f.InsertString(v)
+	}
+
+	m := openMapped(t, writeFilter(t, dir, f))
+	defer m.Close()
+
+	for i, v := range td {
+		expected := i < 3
+		if expected != m.MayContainString(v) {
+			t.Errorf("[%v] want MayContainString(%q) = %v, got %v", i, v, expected, !expected)
+		}
+	}
+
+	if 3 != m.Items() {
+		t.Errorf("want Items() = 3, got %v", m.Items())
+	}
+
+	if err := m.VerifyChecksums(); err != nil {
+		t.Errorf("want VerifyChecksums() = nil, got %v", err)
+	}
+
+	if err := m.InsertString("rank"); err != ErrReadOnly {
+		t.Errorf("want InsertString() err = ErrReadOnly, got %v", err)
+	}
+
+	if err := m.InsertN(0x01, 2); err != ErrReadOnly {
+		t.Errorf("want InsertN() err = ErrReadOnly, got %v", err)
+	}
+
+	if _, err := m.Delete(m.Hash([]byte("Hello world"))); err != ErrReadOnly {
+		t.Errorf("want Delete() err = ErrReadOnly, got %v", err)
+	}
+
+	if _, err := m.DeleteN(0x01, 2); err != ErrReadOnly {
+		t.Errorf("want DeleteN() err = ErrReadOnly, got %v", err)
+	}
+
+	// resizing copies the entries into a writable filter.
+	g, err := m.Resize(1000000)
+	if err != nil {
+		t.Fatalf("want Resize() err = nil, got %v", err)
+	}
+
+	if err := g.InsertString("rank"); err != nil {
+		t.Errorf("want InsertString() on resized filter err = nil, got %v", err)
+	}
+
+	if err := m.Close(); err != nil {
+		t.Errorf("want Close() err = nil, got %v", err)
+	}
+}
+
+func Test_OpenMapped_invalid_files(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "rsqf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := writeFilter(t, dir, New(1000))
+	b, _ := ioutil.ReadFile(path)
+
+	td := []struct {
+		name     string
+		data     []byte
+		expected error
+	}{
+		{"short", b[:8], ErrInvalidFormat},
+		{"truncated", b[:len(b)-8], ErrInvalidFormat},
+		{"magic", append([]byte("XSQF"), b[4:]...), ErrInvalidFormat},
+		// earlier versions keep the remainders apart from their blocks.
+		{"v2", legacy(b, 2), ErrUnsupportedVersion},
+		{"v3", legacy(b, 3), ErrUnsupportedVersion},
+	}
+
+	for _, v := range td {
+		p := filepath.Join(dir, v.name)
+		ioutil.WriteFile(p, v.data, 0644)
+		_, err := OpenMapped(p)
+		if v.expected != err {
+			t.Errorf("[%v] want OpenMapped() err = %v, got %v", v.name, v.expected, err)
+		}
+	}
+
+	_, err = OpenMapped(filepath.Join(dir, "missing"))
+	if !os.IsNotExist(err) {
+		t.Errorf("want OpenMapped(missing) err to be not exist, got %v", err)
+	}
+
+	// the ranges are only checked on request.
+	p := filepath.Join(dir, "checksum")
+	ioutil.WriteFile(p, append(append([]byte(nil), b[:len(b)-1]...), b[len(b)-1]+1), 0644)
+	m := openMapped(t, p)
+	defer m.Close()
+
+	err = m.VerifyChecksums()
+	if err != ErrChecksum {
+		t.Errorf("want VerifyChecksums() = ErrChecksum, got %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+package rsqf
+
+import "encoding/binary"
+
+const (
+	murmurC1 uint64 = 0x87c37b91114253d5
+	murmurC2 uint64 = 0x4cf5ad432745937f
+)
+
+type murmurHasher struct {
+	seed uint64
+}
+
+// NewMurmur3 returns a hasher that uses the lower 64-bits of MurmurHash3
+// x64 128. Seeds below 2^32 match the reference implementation.
+//
+// Reference: https://github.com/aappleby/smhasher/blob/master/src/MurmurHash3.cpp
+func NewMurmur3(seed uint64) Hasher {
+	return &murmurHasher{seed: seed}
+}
+
+func (h *murmurHasher) ID() HasherID {
+	return Murmur3
+}
+
+func (h *murmurHasher) Seed() uint64 {
+	return h.seed
+}
+
+func murmurFmix(k uint64) uint64 {
+	k ^= k >> 33
+	k *= 0xff51afd7ed558ccd
+	k ^= k >> 33
+	k *= 0xc4ceb9fe1a85ec53
+	k ^= k >> 33
+	return k
+}
+
+func (h *murmurHasher) Sum64(b []byte) uint64 {
+	n := uint64(len(b))
+	h1, h2 := h.seed, h.seed
+
+	for ; len(b) >= 16; b = b[16:] {
+		k1 := binary.LittleEndian.Uint64(b[0:8])
+		k2 := binary.LittleEndian.Uint64(b[8:16])
+
+		k1 *= murmurC1
+		k1 = rotl(k1, 31)
+		k1 *= murmurC2
+		h1 ^= k1
+
+		h1 = rotl(h1, 27)
+		h1 += h2
+		h1 = h1*5 + 0x52dce729
+
+		k2 *= murmurC2
+		k2 = rotl(k2, 33)
+		k2 *= murmurC1
+		h2 ^= k2
+
+		h2 = rotl(h2, 31)
+		h2 += h1
+		h2 = h2*5 + 0x38495ab5
+	}
+
+	var k1, k2 uint64
+	for i := len(b) - 1; i >= 8; i-- {
+		k2 = k2<<8 | uint64(b[i])
+	}
+	if len(b) > 8 {
+		k2 *= murmurC2
+		k2 = rotl(k2, 33)
+		k2 *= murmurC1
+		h2 ^= k2
+	}
+
+	tail := b
+	if len(tail) > 8 {
+		tail = tail[:8]
+	}
+	for i := len(tail) - 1; i >= 0; i-- {
+		k1 = k1<<8 | uint64(tail[i])
+	}
+	if len(tail) > 0 {
+		k1 *= murmurC1
+		k1 = rotl(k1, 31)
+		k1 *= murmurC2
+		h1 ^= k1
+	}
+
+	h1 ^= n
+	h2 ^= n
+
+	h1 += h2
+	h2 += h1
+
+	h1 = murmurFmix(h1)
+	h2 = murmurFmix(h2)
+
+	h1 += h2
+
+	return h1
+}
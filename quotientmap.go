@@ -0,0 +1,156 @@
+package rsqf
+
+import (
+	"errors"
+	"math"
+)
+
+// ErrInvalidValue is returned when a value doesn't fit in the value bits of a
+// QuotientMap.
+var ErrInvalidValue = errors.New("RSQF value exceeds the map's value bits")
+
+/*
+QuotientMap associates a small value with each key, a maplet. Every slot of
+the underlying filter is r + v bits wide and holds the remainder of the key
+followed by its value;
+
+	slot = h1 << v | value
+
+Runs are kept in order of the slots so the values for a remainder are stored
+together. A lookup can match the remainder of another key so Get returns
+every candidate value, at most one of them belongs to a key that wasn't
+inserted with the same fingerprint.
+
+A key may be associated with several values, Put adds to those already stored
+rather than replacing them.
+*/
+type QuotientMap struct {
+	f         *Rsqf // fingerprints are stored as h0 << (r + v) | slot.
+	remainder uint64
+	value     uint64 // number of value bits in each slot.
+	vMask     uint64
+}
+
+// NewQuotientMap returns a QuotientMap for c.N keys with a false-positive rate
+// of c.ErrRate and values of up to valueBits bits. Counting isn't supported.
+func NewQuotientMap(c Config, valueBits uint64) (*QuotientMap, error) {
+	rate := c.ErrRate
+	if rate == 0 {
+		rate = errRate
+	}
+
+	if rate <= 0 || rate >= 1 {
+		return nil, ErrInvalidRate
+	}
+
+	if c.N < 1 || c.Counting {
+		return nil, ErrInvalidSize
+	}
+
+	r := math.Ceil(math.Log2(1 / rate))
+	w := r + float64(valueBits)
+	p := math.Max(math.Ceil(math.Log2(c.N)), blockBits) + w
+	if p > 64 || w > 64-blockBits {
+		return nil, ErrInvalidSize
+	}
+
+	f := newRsqf(uint64(p), uint64(w))
+	if c.Hasher != nil {
+		f.hasher = c.Hasher
+	}
+
+	return &QuotientMap{
+		f:         f,
+		remainder: uint64(r),
+		value:     valueBits,
+		vMask:     pow2(valueBits) - 1,
+	}, nil
+}
+
+// Items returns the number of key and value pairs stored in the map.
+func (m *QuotientMap) Items() uint64 {
+	return m.f.items
+}
+
+// Hash applies the map's hashing algorithm to b.
+func (m *QuotientMap) Hash(b []byte) uint64 {
+	return m.f.Hash(b)
+}
+
+// slot returns the fingerprint in the underlying filter for the hash x and
+// value.
+func (m *QuotientMap) slot(x, value uint64) uint64 {
+	q, r := m.f.quotient, m.remainder
+	h0 := x >> r & (pow2(q) - 1)
+	h1 := x & (pow2(r) - 1)
+	return (h0<<r|h1)<<m.value | value
+}
+
+// Put associates value with the hash x.
+func (m *QuotientMap) Put(x, value uint64) error {
+	if value > m.vMask {
+		return ErrInvalidValue
+	}
+	return m.f.Insert(m.slot(x, value))
+}
+
+// PutBytes hashes b and associates value with the result.
+func (m *QuotientMap) PutBytes(b []byte, value uint64) error {
+	return m.Put(m.Hash(b), value)
+}
+
+// PutString hashes s and associates value with the result.
+func (m *QuotientMap) PutString(s string, value uint64) error {
+	return m.PutBytes([]byte(s), value)
+}
+
+// Get returns the values stored for the remainder of the hash x in ascending
+// order. ok is false if there are none.
+func (m *QuotientMap) Get(x uint64) (values []uint64, ok bool) {
+	q := m.f
+	h0, v := q.split(m.slot(x, 0))
+	h1 := v >> m.value
+
+	if !q.isOccupied(h0) {
+		return nil, false
+	}
+
+	end, ok := q.lastEnd(h0)
+	if !ok || end >= q.slots() {
+		return nil, false
+	}
+	for s := q.runStart(h0, end); s <= end; s++ {
+		v := q.Get(s)
+		if v>>m.value > h1 {
+			break
+		}
+		if v>>m.value == h1 {
+			values = append(values, v&m.vMask)
+		}
+	}
+	return values, len(values) > 0
+}
+
+// GetBytes hashes b and returns the values stored for the result.
+func (m *QuotientMap) GetBytes(b []byte) ([]uint64, bool) {
+	return m.Get(m.Hash(b))
+}
+
+// GetString hashes s and returns the values stored for the result.
+func (m *QuotientMap) GetString(s string) ([]uint64, bool) {
+	return m.GetBytes([]byte(s))
+}
+
+// Delete removes one association of value with the hash x. It returns false
+// if the pair was not found.
+func (m *QuotientMap) Delete(x, value uint64) (bool, error) {
+	if value > m.vMask {
+		return false, ErrInvalidValue
+	}
+	return m.f.Delete(m.slot(x, value))
+}
+
+// Verify checks the invariants of the underlying filter. See Rsqf.Verify.
+func (m *QuotientMap) Verify() error {
+	return m.f.Verify()
+}
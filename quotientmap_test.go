@@ -0,0 +1,139 @@
+package rsqf_test
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+
+	. "github.com/nfisher/rsqf"
+)
+
+func Test_NewQuotientMap_should_reject_invalid_config(t *testing.T) {
+	t.Parallel()
+	td := []struct {
+		name string
+		c    Config
+		v    uint64
+		err  error
+	}{
+		{"rate", Config{N: 1000, ErrRate: -1}, 4, ErrInvalidRate},
+		{"size", Config{N: 0}, 4, ErrInvalidSize},
+		{"counting", Config{N: 1000, Counting: true}, 4, ErrInvalidSize},
+		{"value bits", Config{N: 1000}, 50, ErrInvalidSize},
+	}
+
+	for _, tc := range td {
+		_, err := NewQuotientMap(tc.c, tc.v)
+		if err != tc.err {
+			t.Errorf("%v want err = %v, got %v", tc.name, tc.err, err)
+		}
+	}
+}
+
+func Test_QuotientMap_should_return_values(t *testing.T) {
+	t.Parallel()
+	m, err := NewQuotientMap(Config{N: 1000}, 4)
+	if err != nil {
+		t.Fatalf("want NewQuotientMap() err = nil, got %v", err)
+	}
+
+	// p = 19 so keys are their own fingerprint.
+	r := rand.New(rand.NewSource(61))
+	want := make(map[uint64]uint64)
+	for len(want) < 700 {
+		x := r.Uint64() & (1<<19 - 1)
+		if _, ok := want[x]; ok {
+			continue
+		}
+		want[x] = uint64(r.Intn(16))
+
+		err := m.Put(x, want[x])
+		if err != nil {
+			t.Fatalf("want Put(0x%X, %v) err = nil, got %v", x, want[x], err)
+		}
+	}
+
+	for x, v := range want {
+		values, ok := m.Get(x)
+		if !ok || !reflect.DeepEqual(values, []uint64{v}) {
+			t.Fatalf("want Get(0x%X) = [%v], true, got %v, %v", x, v, values, ok)
+		}
+	}
+
+	if m.Items() != uint64(len(want)) {
+		t.Errorf("want Items() = %v, got %v", len(want), m.Items())
+	}
+
+	err = m.Verify()
+	if err != nil {
+		t.Errorf("want Verify() = nil, got %v", err)
+	}
+}
+
+func Test_QuotientMap_should_return_every_candidate(t *testing.T) {
+	t.Parallel()
+	m, _ := NewQuotientMap(Config{N: 1000}, 8)
+
+	// 0x1234 and 0x1234 + 2^19 share a fingerprint.
+	td := []struct {
+		x uint64
+		v uint64
+	}{
+		{0x1234, 200},
+		{0x1234 + 1<<19, 7},
+		{0x1234, 9},
+		{0x1235, 1},
+	}
+
+	for _, tc := range td {
+		err := m.Put(tc.x, tc.v)
+		if err != nil {
+			t.Fatalf("want Put(0x%X, %v) err = nil, got %v", tc.x, tc.v, err)
+		}
+	}
+
+	values, ok := m.Get(0x1234)
+	if !ok || !reflect.DeepEqual(values, []uint64{7, 9, 200}) {
+		t.Errorf("want Get(0x1234) = [7 9 200], true, got %v, %v", values, ok)
+	}
+
+	ok, err := m.Delete(0x1234, 9)
+	if !ok || err != nil {
+		t.Errorf("want Delete(0x1234, 9) = true, nil, got %v, %v", ok, err)
+	}
+
+	ok, err = m.Delete(0x1234, 9)
+	if ok || err != nil {
+		t.Errorf("want Delete(0x1234, 9) = false, nil, got %v, %v", ok, err)
+	}
+
+	values, ok = m.Get(0x1234)
+	if !ok || !reflect.DeepEqual(values, []uint64{7, 200}) {
+		t.Errorf("want Get(0x1234) = [7 200], true, got %v, %v", values, ok)
+	}
+
+	values, ok = m.Get(0x1236)
+	if ok || values != nil {
+		t.Errorf("want Get(0x1236) = nil, false, got %v, %v", values, ok)
+	}
+
+	err = m.Put(0x1236, 256)
+	if err != ErrInvalidValue {
+		t.Errorf("want Put(0x1236, 256) err = ErrInvalidValue, got %v", err)
+	}
+}
+
+func Test_QuotientMap_should_hash_strings(t *testing.T) {
+	t.Parallel()
+	m, _ := NewQuotientMap(Config{N: 1000, Hasher: NewXXHash64(5)}, 3)
+
+	err := m.PutString("shard-key", 5)
+	if err != nil {
+		t.Fatalf("want PutString() err = nil, got %v", err)
+	}
+
+	values, ok := m.GetString("shard-key")
+	if !ok || !reflect.DeepEqual(values, []uint64{5}) {
+		t.Errorf("want GetString() = [5], true, got %v, %v", values, ok)
+	}
+}
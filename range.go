@@ -0,0 +1,67 @@
+package rsqf
+
+// fingerprintRange converts the hashes lo and hi to an inclusive range of
+// fingerprints. A hi above the universe is clamped to the largest fingerprint,
+// a lo above it is past every fingerprint. The hashes of an ordered hasher
+// are never above it, see checkOrdered. ok is false if the range is empty.
+func (q *Rsqf) fingerprintRange(lo, hi uint64) (uint64, uint64, bool) {
+	pMask := q.qMask | q.rMask
+	if hi > pMask {
+		hi = pMask
+	}
+	return lo, hi, lo <= hi
+}
+
+// MayContainRange tests if any fingerprint in the filter is in [lo, hi]. The
+// range is over fingerprints, the lowest p bits of a hash, so it is only
+// meaningful for an order-preserving hasher such as NewOrdered. False
+// positives are possible where keys outside of the range share a fingerprint
+// with one inside of it.
+func (q *Rsqf) MayContainRange(lo, hi uint64) bool {
+	lo, hi, ok := q.fingerprintRange(lo, hi)
+	if !ok {
+		return false
+	}
+
+	return q.countRange(lo, hi, true) > 0
+}
+
+// CountRange returns the number of instances with a fingerprint in [lo, hi].
+// The first fingerprint is found with the block offsets, the rest of the range
+// is a walk of the runs. See MayContainRange.
+func (q *Rsqf) CountRange(lo, hi uint64) uint64 {
+	lo, hi, ok := q.fingerprintRange(lo, hi)
+	if !ok {
+		return 0
+	}
+
+	return q.countRange(lo, hi, false)
+}
+
+// countRange returns the number of instances with a fingerprint in [lo, hi].
+// It stops at the first fingerprint if first is true.
+func (q *Rsqf) countRange(lo, hi uint64, first bool) uint64 {
+	if q.finishGrow() != nil {
+		// a migration that can't complete is read from both filters,
+		// fingerprints below the boundary are only in the larger one.
+		g := q.grow
+		c := g.next.countRange(lo, hi, first)
+		if hi >= g.boundary && (c == 0 || !first) {
+			if lo < g.boundary {
+				lo = g.boundary
+			}
+			c += q.plain().countRange(lo, hi, first)
+		}
+		return c
+	}
+
+	var c uint64
+	it := q.Iterator()
+	for it.Seek(lo); it.Next() && it.Hash() <= hi; {
+		c += it.Count()
+		if first {
+			break
+		}
+	}
+	return c
+}
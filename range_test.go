@@ -0,0 +1,243 @@
+package rsqf_test
+
+import (
+	"encoding/binary"
+	"testing"
+
+	. "github.com/nfisher/rsqf"
+)
+
+type rangeCase struct {
+	lo, hi   uint64
+	expected uint64
+}
+
+func checkRanges(t *testing.T, f *Rsqf, td []rangeCase) {
+	t.Helper()
+	for i, v := range td {
+		if v.expected != f.CountRange(v.lo, v.hi) {
+			t.Errorf("[%v] want CountRange(0x%X, 0x%X) = %v, got %v", i, v.lo, v.hi, v.expected, f.CountRange(v.lo, v.hi))
+		}
+
+		if (v.expected > 0) != f.MayContainRange(v.lo, v.hi) {
+			t.Errorf("[%v] want MayContainRange(0x%X, 0x%X) = %v, got %v", i, v.lo, v.hi, v.expected > 0, !(v.expected > 0))
+		}
+	}
+}
+
+func Test_CountRange_should_cover_the_universe(t *testing.T) {
+	t.Parallel()
+	// p = 19, 0x7FFFF is the last fingerprint.
+	f := New(1000)
+	for _, x := range []uint64{0x00000, 0x00201, 0x7FFFF} {
+		f.Insert(x)
+	}
+
+	checkRanges(t, f, []rangeCase{
+		{0x00000, 0x7FFFF, 3},
+		{0x00000, 0x00000, 1},
+		{0x00001, 0x7FFFE, 1},
+		{0x7FFFF, 0x7FFFF, 1},
+		// hi is clamped to the last fingerprint.
+		{0x7FFFF, 0xFFFFFFFFFFFFFFFF, 1},
+		{0x00001, 1 << 40, 2},
+	})
+}
+
+func Test_CountRange_should_walk_displaced_runs(t *testing.T) {
+	t.Parallel()
+	f := New(1000)
+	// h0 = 0 fills slots 0 to 2 and pushes the run for h0 = 1 to slot 3.
+	for _, x := range []uint64{0x0001, 0x0003, 0x0005, 0x0201, 0x0207} {
+		f.Insert(x)
+	}
+
+	checkRanges(t, f, []rangeCase{
+		{0x0002, 0x0004, 1},
+		{0x0004, 0x0200, 1},
+		{0x0006, 0x0200, 0},
+		{0x0200, 0x0201, 1},
+		{0x0006, 0x0206, 1},
+		{0x0002, 0x0207, 4},
+	})
+}
+
+func Test_CountRange_should_read_runs_in_the_overflow_slots(t *testing.T) {
+	t.Parallel()
+	// q = 10 and r = 9, the run for the last home slot spills into the
+	// overflow slots.
+	f := New(1000)
+	for i := uint64(0); i < 150; i++ {
+		f.Insert(0x3FF<<9 | i)
+	}
+
+	checkRanges(t, f, []rangeCase{
+		{0x3FE << 9, 0x3FF<<9 - 1, 0},
+		{0x3FF << 9, 0x3FF<<9 | 149, 150},
+		{0x3FF<<9 | 100, 0xFFFFFFFFFFFFFFFF, 50},
+		{0x3FF<<9 | 150, 0xFFFFFFFFFFFFFFFF, 0},
+	})
+}
+
+func Test_CountRange_should_add_counters(t *testing.T) {
+	t.Parallel()
+	f := NewCounting(1000)
+	// a count of 300 takes more than one slot.
+	f.InsertN(0x0005, 3)
+	f.InsertN(0x0201, 300)
+
+	checkRanges(t, f, []rangeCase{
+		{0x0000, 0x0005, 3},
+		{0x0006, 0x0201, 300},
+		{0x0000, 0xFFFFFFFFFFFFFFFF, 303},
+	})
+}
+
+func Test_MayContainRange_empty_ranges(t *testing.T) {
+	t.Parallel()
+	f := New(1000)
+	f.Insert(0x0205)
+
+	td := [][]uint64{
+		// lo, hi
+		{0x0206, 0x0205},
+		{0x0206, 0xFFFFFFFFFFFFFFFF},
+		{1 << 19, 0xFFFFFFFFFFFFFFFF},
+		{0x0000, 0x0204},
+	}
+
+	for i, v := range td {
+		if f.MayContainRange(v[0], v[1]) {
+			t.Errorf("[%v] want MayContainRange(0x%X, 0x%X) = false, got true", i, v[0], v[1])
+		}
+		if 0 != f.CountRange(v[0], v[1]) {
+			t.Errorf("[%v] want CountRange(0x%X, 0x%X) = 0, got %v", i, v[0], v[1], f.CountRange(v[0], v[1]))
+		}
+	}
+}
+
+func Test_Ordered_hasher_time_buckets(t *testing.T) {
+	t.Parallel()
+	// unix timestamps in seconds fit in 32 bits, p = 26 so the shift is 6.
+	f, err := NewWithConfig(Config{N: 100000, Ordered: true, KeyBits: 32})
+	if err != nil {
+		t.Fatalf("want NewWithConfig() err = nil, got %v", err)
+	}
+
+	key := func(ts uint64) []byte {
+		var b [8]byte
+		binary.BigEndian.PutUint64(b[:], ts)
+		return b[:]
+	}
+
+	// hourly buckets from 2017-01-01 with a gap between hours 100 and 200.
+	const start = 1483228800
+	const hour = 3600
+	for i := uint64(0); i < 1000; i++ {
+		if i > 100 && i < 200 {
+			continue
+		}
+		err := f.InsertBytes(key(start + i*hour))
+		if err != nil {
+			t.Fatalf("[%v] want InsertBytes() err = nil, got %v", i, err)
+		}
+	}
+
+	td := []struct {
+		from, to uint64
+		expected uint64
+	}{
+		{0, 10, 11},
+		{90, 110, 11},
+		{101, 199, 0},
+		{120, 180, 0},
+		{150, 250, 51},
+		{999, 1500, 1},
+	}
+
+	for i, v := range td {
+		lo, hi := f.Hash(key(start+v.from*hour)), f.Hash(key(start+v.to*hour))
+		if v.expected != f.CountRange(lo, hi) {
+			t.Errorf("[%v] want CountRange(hours %v-%v) = %v, got %v", i, v.from, v.to, v.expected, f.CountRange(lo, hi))
+		}
+
+		if (v.expected > 0) != f.MayContainRange(lo, hi) {
+			t.Errorf("[%v] want MayContainRange(hours %v-%v) = %v, got %v", i, v.from, v.to, v.expected > 0, !(v.expected > 0))
+		}
+	}
+
+	if Ordered != f.Hasher().ID() || 1<<25 != f.Hasher().Sum64(key(1<<31)) {
+		t.Errorf("want ordered hasher with a shift of KeyBits - p, got %v, 0x%X", f.Hasher().ID(), f.Hasher().Sum64(key(1<<31)))
+	}
+
+	// the key bits and shift are kept by the binary format.
+	b, _ := f.MarshalBinary()
+	var g Rsqf
+	err = g.UnmarshalBinary(b)
+	if err != nil {
+		t.Fatalf("want UnmarshalBinary() err = nil, got %v", err)
+	}
+	if g.Hash(key(start)) != f.Hash(key(start)) || g.Hash(key(1<<40)) != f.Hash(key(1<<40)) {
+		t.Errorf("want decoded Hash() = 0x%X, got 0x%X", f.Hash(key(start)), g.Hash(key(start)))
+	}
+}
+
+func Test_NewWithConfig_ordered_key_bits(t *testing.T) {
+	t.Parallel()
+	// p = 19, keys of up to 19 bits aren't shifted.
+	td := []struct {
+		bits     uint64
+		key      []byte
+		expected uint64
+	}{
+		{0, []byte{0x02, 0x01}, 0x0201 << 3},
+		{64, []byte{0x02, 0x01}, 0x0201 << 3},
+		{32, []byte{0, 0, 0, 0, 0x80, 0, 0, 0}, 1 << 18},
+		// wider keys are saturated to the last fingerprint.
+		{32, []byte{0, 0, 0x01, 0, 0, 0, 0, 0}, 0x7FFFF},
+		{16, []byte{0, 0, 0, 0, 0, 0, 0x02, 0x01}, 0x0201},
+	}
+
+	for i, v := range td {
+		f, err := NewWithConfig(Config{N: 1000, Ordered: true, KeyBits: v.bits})
+		if err != nil {
+			t.Fatalf("[%v] want NewWithConfig() err = nil, got %v", i, err)
+		}
+
+		if v.expected != f.Hash(v.key) {
+			t.Errorf("[%v] want Hash() = 0x%X, got 0x%X", i, v.expected, f.Hash(v.key))
+		}
+	}
+}
+
+func Test_NewWithConfig_should_keep_the_ordered_shift(t *testing.T) {
+	t.Parallel()
+	// p = 19 so the smallest shift is 45.
+	f, err := NewWithConfig(Config{N: 1000, Hasher: NewOrdered(50)})
+	if err != nil {
+		t.Fatalf("want NewWithConfig() err = nil, got %v", err)
+	}
+
+	if 0x0201>>2 != f.Hash([]byte{0x02, 0x01}) {
+		t.Errorf("want Hash() = 0x%X, got 0x%X", 0x0201>>2, f.Hash([]byte{0x02, 0x01}))
+	}
+
+	td := []struct {
+		c        Config
+		expected error
+	}{
+		{Config{N: 1000, Hasher: NewOrdered(45)}, nil},
+		{Config{N: 1000, Hasher: NewOrdered(44)}, ErrInvalidShift},
+		{Config{N: 1000, Hasher: NewOrdered(0)}, ErrInvalidShift},
+		{Config{N: 1000, Hasher: NewOrdered(64)}, ErrInvalidShift},
+		{Config{N: 1000, Hasher: NewOrdered(50), Ordered: true}, ErrOrderedHasher},
+		{Config{N: 1000, Ordered: true, KeyBits: 65}, ErrInvalidKeyBits},
+	}
+
+	for i, v := range td {
+		_, err := NewWithConfig(v.c)
+		if err != v.expected {
+			t.Errorf("[%v] want NewWithConfig() err = %v, got %v", i, v.expected, err)
+		}
+	}
+}
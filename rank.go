@@ -0,0 +1,17 @@
+package rsqf
+
+import "math/bits"
+
+// rankMaskOf returns a mask of the bits in positions 0 to i. Positions above 63
+// include every bit.
+func rankMaskOf(i uint64) uint64 {
+	if i > 63 {
+		i = 63
+	}
+	return ^uint64(0) >> (63 - i)
+}
+
+// rankOnesCount is Rank using math/bits.OnesCount64.
+func rankOnesCount(B, i uint64) uint64 {
+	return uint64(bits.OnesCount64(B & rankMaskOf(i)))
+}
@@ -0,0 +1,57 @@
+package rsqf
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// naiveRank counts the 1s in B up to position i one bit at a time.
+func naiveRank(B, i uint64) uint64 {
+	var c uint64
+	for j := uint64(0); j <= i && j < 64; j++ {
+		c += (B >> j) & 0x1
+	}
+	return c
+}
+
+func Test_Rank_implementations_should_match_naive(t *testing.T) {
+	t.Parallel()
+	impls := map[string]func(B, i uint64) uint64{
+		"Rank":          Rank,
+		"rankOnesCount": rankOnesCount,
+	}
+
+	words := []uint64{0x0, 0x1, 0x8000000000000000, 0xFFFFFFFFFFFFFFFF}
+	r := rand.New(rand.NewSource(19))
+	for i := 0; i < 500; i++ {
+		words = append(words, r.Uint64())
+	}
+
+	for name, fn := range impls {
+		for _, B := range words {
+			// positions above 63 count every bit.
+			for i := uint64(0); i <= 66; i++ {
+				expected := naiveRank(B, i)
+				actual := fn(B, i)
+				if expected != actual {
+					t.Fatalf("[%v] want rank(0x%X, %v) = %v, got %v", name, B, i, expected, actual)
+				}
+			}
+		}
+	}
+}
+
+func Benchmark_rankOnesCount(b *testing.B) {
+	words := make([]uint64, 1024)
+	for i := range words {
+		words[i] = uint64(i+1) * 0x9E3779B97F4A7C15
+	}
+
+	var c uint64
+	for i := 0; i < b.N; i++ {
+		c += rankOnesCount(words[i&1023], uint64(i&63))
+	}
+	rankInternalSink = c
+}
+
+var rankInternalSink uint64
@@ -0,0 +1,16 @@
+//go:build !rsqf_table
+// +build !rsqf_table
+
+package rsqf
+
+// Rank returns the number of 1s in B up to and including position i. Positions
+// above 63 count every 1 in B.
+//
+// math/bits.OnesCount64 is an intrinsic. On amd64 the compiler inlines a
+// POPCNT instruction guarded by the CPU feature the runtime detects at start
+// up, with a software count as the fallback, so Rank already selects POPCNT
+// by CPU. An assembly POPCNT can't be inlined and the call costs more than
+// the feature check.
+func Rank(B, i uint64) uint64 {
+	return rankOnesCount(B, i)
+}
@@ -0,0 +1,118 @@
+//go:build rsqf_table
+// +build rsqf_table
+
+package rsqf
+
+// rankByteTable is a quick look-up table for the number of bits that are 1 in a
+// given byte. Generated using the following program in Go 1.9;
+//
+//	package main
+//
+//	import (
+//		"fmt"
+//		"math/bits"
+//	)
+//
+//	func main() {
+//		var i uint
+//		for ; i < 256; i++ {
+//			fmt.Printf("%v, ", bits.OnesCount(i))
+//			if i%16 == 0 {
+//				fmt.Println("")
+//			}
+//		}
+//	}
+//
+// This almost doubles the performance of Rank() over the previous nibble
+// oriented lookups.
+var rankByteTable = [256]uint64{
+	0,
+	1, 1, 2, 1, 2, 2, 3, 1, 2, 2, 3, 2, 3, 3, 4, 1,
+	2, 2, 3, 2, 3, 3, 4, 2, 3, 3, 4, 3, 4, 4, 5, 1,
+	2, 2, 3, 2, 3, 3, 4, 2, 3, 3, 4, 3, 4, 4, 5, 2,
+	3, 3, 4, 3, 4, 4, 5, 3, 4, 4, 5, 4, 5, 5, 6, 1,
+	2, 2, 3, 2, 3, 3, 4, 2, 3, 3, 4, 3, 4, 4, 5, 2,
+	3, 3, 4, 3, 4, 4, 5, 3, 4, 4, 5, 4, 5, 5, 6, 2,
+	3, 3, 4, 3, 4, 4, 5, 3, 4, 4, 5, 4, 5, 5, 6, 3,
+	4, 4, 5, 4, 5, 5, 6, 4, 5, 5, 6, 5, 6, 6, 7, 1,
+	2, 2, 3, 2, 3, 3, 4, 2, 3, 3, 4, 3, 4, 4, 5, 2,
+	3, 3, 4, 3, 4, 4, 5, 3, 4, 4, 5, 4, 5, 5, 6, 2,
+	3, 3, 4, 3, 4, 4, 5, 3, 4, 4, 5, 4, 5, 5, 6, 3,
+	4, 4, 5, 4, 5, 5, 6, 4, 5, 5, 6, 5, 6, 6, 7, 2,
+	3, 3, 4, 3, 4, 4, 5, 3, 4, 4, 5, 4, 5, 5, 6, 3,
+	4, 4, 5, 4, 5, 5, 6, 4, 5, 5, 6, 5, 6, 6, 7, 3,
+	4, 4, 5, 4, 5, 5, 6, 4, 5, 5, 6, 5, 6, 6, 7, 4,
+	5, 5, 6, 5, 6, 6, 7, 5, 6, 6, 7, 6, 7, 7, 8,
+}
+
+var rankMasks = [64]uint64{
+	0x0000000000000001, 0x0000000000000003,
+	0x0000000000000007, 0x000000000000000F,
+	0x000000000000001F, 0x000000000000003F,
+	0x000000000000007F, 0x00000000000000FF,
+	0x00000000000001FF, 0x00000000000003FF,
+	0x00000000000007FF, 0x0000000000000FFF,
+	0x0000000000001FFF, 0x0000000000003FFF,
+	0x0000000000007FFF, 0x000000000000FFFF,
+	0x000000000001FFFF, 0x000000000003FFFF,
+	0x000000000007FFFF, 0x00000000000FFFFF,
+	0x00000000001FFFFF, 0x00000000003FFFFF,
+	0x00000000007FFFFF, 0x0000000000FFFFFF,
+	0x0000000001FFFFFF, 0x0000000003FFFFFF,
+	0x0000000007FFFFFF, 0x000000000FFFFFFF,
+	0x000000001FFFFFFF, 0x000000003FFFFFFF,
+	0x000000007FFFFFFF, 0x00000000FFFFFFFF,
+	0x00000001FFFFFFFF, 0x00000003FFFFFFFF,
+	0x00000007FFFFFFFF, 0x0000000FFFFFFFFF,
+	0x0000001FFFFFFFFF, 0x0000003FFFFFFFFF,
+	0x0000007FFFFFFFFF, 0x000000FFFFFFFFFF,
+	0x000001FFFFFFFFFF, 0x000003FFFFFFFFFF,
+	0x000007FFFFFFFFFF, 0x00000FFFFFFFFFFF,
+	0x00001FFFFFFFFFFF, 0x00003FFFFFFFFFFF,
+	0x00007FFFFFFFFFFF, 0x0000FFFFFFFFFFFF,
+	0x0001FFFFFFFFFFFF, 0x0003FFFFFFFFFFFF,
+	0x0007FFFFFFFFFFFF, 0x000FFFFFFFFFFFFF,
+	0x001FFFFFFFFFFFFF, 0x003FFFFFFFFFFFFF,
+	0x007FFFFFFFFFFFFF, 0x00FFFFFFFFFFFFFF,
+	0x01FFFFFFFFFFFFFF, 0x03FFFFFFFFFFFFFF,
+	0x07FFFFFFFFFFFFFF, 0x0FFFFFFFFFFFFFFF,
+	0x1FFFFFFFFFFFFFFF, 0x3FFFFFFFFFFFFFFF,
+	0x7FFFFFFFFFFFFFFF, 0xFFFFFFFFFFFFFFFF,
+}
+
+const rankMask uint64 = 0xFF
+
+// Rank returns the number of 1s in B up to and including position i. Positions
+// above 63 count every 1 in B.
+//
+// This is the byte table version kept for comparison with the popcount
+// versions, build with -tags rsqf_table to use it.
+func Rank(B, i uint64) uint64 {
+	if i > 63 {
+		i = 63
+	}
+
+	// mask elimnates need for conditions which would invalidate the pipeline.
+	// Rank is currently 6ns. Might try 2^20 or 2^24 table but it's big!
+	masked := B & rankMasks[i]
+
+	// see rank_amd64.go for the POPCNT version.
+	c0 := rankByteTable[masked&rankMask]
+	masked = masked >> 8
+	c1 := rankByteTable[masked&rankMask]
+	masked = masked >> 8
+	c2 := rankByteTable[masked&rankMask]
+	masked = masked >> 8
+	c3 := rankByteTable[masked&rankMask]
+
+	masked = masked >> 8
+	c4 := rankByteTable[masked&rankMask]
+	masked = masked >> 8
+	c5 := rankByteTable[masked&rankMask]
+	masked = masked >> 8
+	c6 := rankByteTable[masked&rankMask]
+	masked = masked >> 8
+	c7 := rankByteTable[masked&rankMask]
+
+	return c0 + c1 + c2 + c3 + c4 + c5 + c6 + c7
+}
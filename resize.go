@@ -0,0 +1,76 @@
+package rsqf
+
+import (
+	"errors"
+	"math"
+)
+
+// ErrResizeUniverse is returned when a resize needs more slots than the
+// universe p can address with the remaining remainder bits.
+var ErrResizeUniverse = errors.New("RSQF resize requires a larger universe p")
+
+// ErrResizeSmaller is returned when a resize would not add any slots.
+var ErrResizeSmaller = errors.New("RSQF resize must increase the number of slots")
+
+// Resize returns a new filter with enough slots for newN insertions and the
+// entries of q. The universe p is fixed so every additional quotient bit is
+// taken from the remainder, which doubles the false-positive rate.
+//
+//	q' = log2(newN)
+//	r' = p - q'
+//	h0'(x), h1'(x) = (h0(x) << r | h1(x)) split at r'
+func (q *Rsqf) Resize(newN float64) (*Rsqf, error) {
+	if newN < 1 {
+		return nil, ErrInvalidSize
+	}
+
+	quotient := uint64(math.Ceil(math.Log2(newN)))
+	if quotient <= q.quotient {
+		return nil, ErrResizeSmaller
+	}
+
+	filter, err := q.resized(quotient)
+	if err != nil {
+		return nil, err
+	}
+
+	err = q.finishGrow()
+	if err != nil {
+		return nil, err
+	}
+
+	// fingerprints are unchanged so they stay in ascending order.
+	a := appender{q: filter}
+	c := cursor{q: q}
+	for x, n, ok := c.next(); ok; x, n, ok = c.next() {
+		err := a.add(x, n)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = a.finish()
+	if err != nil {
+		return nil, err
+	}
+
+	return filter, nil
+}
+
+// resized returns an empty filter like q with a quotient of the given number
+// of bits taken from the remainder.
+func (q *Rsqf) resized(quotient uint64) (*Rsqf, error) {
+	// counters need at least two digit values besides the remainder.
+	var minR uint64 = 1
+	if q.counting {
+		minR = 2
+	}
+	if quotient+minR > q.p {
+		return nil, ErrResizeUniverse
+	}
+
+	filter := newRsqf(q.p, q.p-quotient)
+	filter.counting = q.counting
+	filter.hasher = q.hasher
+	return filter, nil
+}
@@ -0,0 +1,101 @@
+package rsqf_test
+
+import (
+	"math/rand"
+	"testing"
+
+	. "github.com/nfisher/rsqf"
+)
+
+func Test_Resize_should_keep_inserted_keys(t *testing.T) {
+	t.Parallel()
+	f, err := NewWithConfig(Config{N: 1000, Hasher: NewXXHash64(3)})
+	if err != nil {
+		t.Fatalf("want NewWithConfig() err = nil, got %v", err)
+	}
+
+	r := rand.New(rand.NewSource(9))
+	inserted := make([]uint64, 700)
+	for i := range inserted {
+		inserted[i] = r.Uint64()
+		err := f.Insert(inserted[i])
+		if err != nil {
+			t.Fatalf("[%v] want Insert(0x%X) err = nil, got %v", i, inserted[i], err)
+		}
+	}
+
+	g, err := f.Resize(100000)
+	if err != nil {
+		t.Fatalf("want Resize(100000) err = nil, got %v", err)
+	}
+
+	if len(g.Q) <= len(f.Q) {
+		t.Errorf("want len(Q) > %v, got %v", len(f.Q), len(g.Q))
+	}
+
+	if err := g.CheckHasher(NewXXHash64(3)); err != nil {
+		t.Errorf("want CheckHasher() = nil, got %v", err)
+	}
+
+	for i, x := range inserted {
+		if !g.MayContainHash(x) {
+			t.Fatalf("[%v] want MayContainHash(0x%X) = true, got false", i, x)
+		}
+	}
+
+	for i, x := range inserted {
+		ok, err := g.Delete(x)
+		if !ok || err != nil {
+			t.Fatalf("[%v] want Delete(0x%X) = true, nil, got %v, %v", i, x, ok, err)
+		}
+	}
+}
+
+func Test_Resize_should_keep_counts(t *testing.T) {
+	t.Parallel()
+	f := NewCounting(1000)
+
+	r := rand.New(rand.NewSource(11))
+	inserted := make([]uint64, 300)
+	for i := range inserted {
+		inserted[i] = r.Uint64()
+		err := f.InsertN(inserted[i], uint64(i%5+1))
+		if err != nil {
+			t.Fatalf("[%v] want InsertN(0x%X) err = nil, got %v", i, inserted[i], err)
+		}
+	}
+
+	g, err := f.Resize(8000)
+	if err != nil {
+		t.Fatalf("want Resize(8000) err = nil, got %v", err)
+	}
+
+	for i, x := range inserted {
+		if f.Count(x) != g.Count(x) {
+			t.Fatalf("[%v] want Count(0x%X) = %v, got %v", i, x, f.Count(x), g.Count(x))
+		}
+	}
+}
+
+func Test_Resize_errors(t *testing.T) {
+	t.Parallel()
+	// p = 19, q = 10.
+	f := New(1000)
+	td := []struct {
+		n        float64
+		expected error
+	}{
+		{0, ErrInvalidSize},
+		{100, ErrResizeSmaller},
+		{1024, ErrResizeSmaller},
+		{1 << 19, ErrResizeUniverse},
+		{1 << 18, nil},
+	}
+
+	for i, v := range td {
+		_, err := f.Resize(v.n)
+		if v.expected != err {
+			t.Errorf("[%v] want Resize(%v) err = %v, got %v", i, v.n, v.expected, err)
+		}
+	}
+}
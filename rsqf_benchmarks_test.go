@@ -14,7 +14,7 @@ func Benchmark_init(b *testing.B) {
 
 func Benchmark_Put_on_high_boundary(b *testing.B) {
 	f := New(100000)
-	r := &f.Q[0].Remainders
+	r := f.Remainders(0)
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		r[0] = 0
@@ -32,7 +32,7 @@ func Benchmark_Put_on_high_boundary(b *testing.B) {
 
 func Benchmark_Put2_on_high_cell(b *testing.B) {
 	f := New(100000)
-	r := &f.Q[0].Remainders
+	r := f.Remainders(0)
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		r[0] = 0
@@ -80,3 +80,106 @@ func Benchmark_Select(b *testing.B) {
 		b.Errorf("want Select() = 63, got %v", c)
 	}
 }
+
+func Benchmark_Insert_100000(b *testing.B) {
+	keys := make([]uint64, 100000)
+	for i := range keys {
+		keys[i] = uint64(i) * 0x9E3779B97F4A7C15
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		f := New(200000)
+		for _, x := range keys {
+			f.Insert(x)
+		}
+	}
+}
+
+func Benchmark_InsertBatch_100000(b *testing.B) {
+	keys := make([]uint64, 100000)
+	for i := range keys {
+		keys[i] = uint64(i) * 0x9E3779B97F4A7C15
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		f := New(200000)
+		f.InsertBatch(keys)
+	}
+}
+
+// lookupFilter returns a filter with 2^22 slots that is half full and a mix of
+// inserted and random hashes to look up.
+func lookupFilter() (*Rsqf, []uint64) {
+	f := New(1 << 22)
+	keys := make([]uint64, 1<<21)
+	for i := range keys {
+		keys[i] = uint64(i) * 0x9E3779B97F4A7C15
+	}
+	f.InsertBatch(keys)
+
+	hashes := make([]uint64, 4096)
+	for i := range hashes {
+		hashes[i] = keys[(i*7919)%len(keys)]
+		if i%2 == 1 {
+			hashes[i] = uint64(i) * 0xC2B2AE3D27D4EB4F
+		}
+	}
+	return f, hashes
+}
+
+func Benchmark_MayContainHash_loop(b *testing.B) {
+	f, hashes := lookupFilter()
+	out := make([]bool, len(hashes))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j, x := range hashes {
+			out[j] = f.MayContainHash(x)
+		}
+	}
+}
+
+func Benchmark_MayContainBatch(b *testing.B) {
+	f, hashes := lookupFilter()
+	out := make([]bool, len(hashes))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		f.MayContainBatch(hashes, out)
+	}
+}
+
+func Benchmark_Select_random(b *testing.B) {
+	words := make([]uint64, 1024)
+	for i := range words {
+		words[i] = uint64(i+1) * 0x9E3779B97F4A7C15
+	}
+
+	var c uint64
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		w := words[i&1023]
+		c += Select(w, uint64(i&31)+1)
+	}
+	selectSink = c
+}
+
+// selectSink stops the compiler from eliminating the calls to Select.
+var selectSink uint64
+
+func Benchmark_Rank_random(b *testing.B) {
+	words := make([]uint64, 1024)
+	for i := range words {
+		words[i] = uint64(i+1) * 0x9E3779B97F4A7C15
+	}
+
+	var c uint64
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c += Rank(words[i&1023], uint64(i&63))
+	}
+	rankSink = c
+}
+
+// rankSink stops the compiler from eliminating the calls to Rank.
+var rankSink uint64
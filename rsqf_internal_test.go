@@ -1,6 +1,7 @@
 package rsqf
 
 import (
+	"math/rand"
 	"testing"
 	"unsafe"
 )
@@ -8,42 +9,43 @@ import (
 func Test_firstAvailableSlot_should_return_error_when_larger_than_Q(t *testing.T) {
 	t.Parallel()
 	f := New(100000)
-	_, err := f.firstAvailableSlot(0x20000)
+	_, err := f.firstAvailableSlot(f.slots())
 	if err != ErrFilterOverflow {
-		t.Errorf("want f.firstAvailableSlot(0x20000) error = ErrFilterOverflow, got %v", err)
+		t.Errorf("want f.firstAvailableSlot(0x%X) error = ErrFilterOverflow, got %v", f.slots(), err)
 	}
 }
 
 func Test_firstAvailableSlot(t *testing.T) {
 	t.Parallel()
 	td := [][]uint64{
-		// h0, occupieds, runends, bi, expected
-		{0x00, 0x00, 0x00, 0, 0x00},
-		{0x00, 0x01, 0x01, 0, 0x01},
-		{0x00, 0x01, 0x08, 0, 0x04},
-		{0x01, 0x01, 0x02, 0, 0x02},
-		{0x00, 0x01, 0x02, 0, 0x02},
-		{0x00, 0x01, 0x04, 0, 0x03},
-		{0x02, 0x02, 0x02, 0, 0x02},
-		{0x02, 0x02, 0x04, 0, 0x03},
-		{0x03, 0x0F, 0x0F, 0, 0x04},
-		{0x80, 0x00, 0x00, 0, 0x80},
-		{0x3E, 0x2000000000000000, 0x4000000000000000, 0, 0x3F},
-		{0x1FFFF, 0x00, 0x00, 0x7FF, 0x1FFFF},
+		// h0, occupieds, runends, bi, expected, offset
+		{0x00, 0x00, 0x00, 0, 0x00, 0},
+		{0x00, 0x01, 0x01, 0, 0x01, 0},
+		{0x00, 0x01, 0x08, 0, 0x04, 3},
+		{0x01, 0x01, 0x02, 0, 0x02, 1},
+		{0x00, 0x01, 0x02, 0, 0x02, 1},
+		{0x00, 0x01, 0x04, 0, 0x03, 2},
+		{0x02, 0x02, 0x02, 0, 0x02, 0},
+		{0x02, 0x02, 0x04, 0, 0x03, 0},
+		{0x03, 0x0F, 0x0F, 0, 0x04, 0},
+		{0x80, 0x00, 0x00, 0, 0x80, 0},
+		{0x3E, 0x2000000000000000, 0x4000000000000000, 0, 0x3F, 0},
+		{0x1FFFF, 0x00, 0x00, 0x7FF, 0x1FFFF, 0},
 	}
 
 	for i, v := range td {
 		f := New(100000)
 
 		bi := v[3]
-		f.Q[bi].Occupieds = v[1]
-		f.Q[bi].Runends = v[2]
+		f.block(bi).Occupieds = v[1]
+		f.block(bi).Runends = v[2]
+		f.block(bi).Offset = v[5]
 
 		h0 := v[0]
 		actual, err := f.firstAvailableSlot(h0)
 
 		if err != nil {
-			t.Errorf("[%v] want err = nil, got %v. len(Q) = 0x%X", i, err, len(f.Q))
+			t.Errorf("[%v] want err = nil, got %v. blocks = 0x%X", i, err, f.blocks())
 			t.Errorf("0x%X", v[0]&f.qMask)
 		}
 
@@ -55,6 +57,35 @@ func Test_firstAvailableSlot(t *testing.T) {
 	}
 }
 
+func Test_firstAvailableSlot_across_blocks(t *testing.T) {
+	t.Parallel()
+	f := New(100000)
+	// run for slot 63 spills into the next block and ends at slot 65.
+	f.block(0).Occupieds = 0x8000000000000000
+	f.block(1).Runends = 0x02
+	f.block(1).Offset = 1
+
+	td := [][]uint64{
+		// h0, expected
+		{0x3F, 0x42},
+		{0x40, 0x42},
+		{0x42, 0x42},
+		{0x3E, 0x3E},
+	}
+
+	for i, v := range td {
+		actual, err := f.firstAvailableSlot(v[0])
+		if err != nil {
+			t.Errorf("[%v] want err = nil, got %v", i, err)
+		}
+
+		if v[1] != actual {
+			t.Errorf("[%v] want firstAvailableSlot(0x%X) = 0x%X, got 0x%X",
+				i, v[0], v[1], actual)
+		}
+	}
+}
+
 func Test_Select(t *testing.T) {
 	t.Parallel()
 	td := [][]uint64{
@@ -118,8 +149,9 @@ func Test_New_filter_should_be_initialised_correctly(t *testing.T) {
 		t.Errorf("want 17, got %v", f.quotient)
 	}
 
-	if 2048 != len(f.Q) {
-		t.Errorf("want len(Q) = 2048, got %v", len(f.Q))
+	// 2^17 home slots and 3620 overflow slots rounded up to a block.
+	if 2105 != f.blocks() {
+		t.Errorf("want 2105 blocks, got %v", f.blocks())
 	}
 
 	var expected uint64 = 0x1FF
@@ -139,20 +171,104 @@ func Test_Hash_should_provide_expected_sum(t *testing.T) {
 	f := New(100000)
 	sum := f.Hash([]byte("Hello world"))
 
-	if 0xCBF29CE484222325 != sum {
-		t.Errorf("want sum = 0xCBF29CE484222325, got 0x%X", sum)
+	if 0x2713F785A33764C7 != sum {
+		t.Errorf("want sum = 0x2713F785A33764C7, got 0x%X", sum)
+	}
+}
+
+func Test_split(t *testing.T) {
+	t.Parallel()
+	f := New(100000)
+	td := [][]uint64{
+		// x, h0, h1
+		{0x0000000, 0x00000, 0x000},
+		{0x00001F0, 0x00000, 0x1F0},
+		{0x00003F0, 0x00001, 0x1F0},
+		{0x3FFFFFF, 0x1FFFF, 0x1FF},
+		{0xFFFFFFFFFC0003F0, 0x00001, 0x1F0},
+	}
+
+	for i, v := range td {
+		h0, h1 := f.split(v[0])
+		if v[1] != h0 || v[2] != h1 {
+			t.Errorf("[%v] want split(0x%X) = 0x%X, 0x%X, got 0x%X, 0x%X",
+				i, v[0], v[1], v[2], h0, h1)
+		}
 	}
 }
 
 func Test_struct_is_contiguous(t *testing.T) {
 	t.Parallel()
 	f := New(10000)
-	p0 := unsafe.Pointer(&f.Q[3])
-	p1 := unsafe.Pointer(&f.Q[4])
-	sz := unsafe.Sizeof(block{})
-	// 1(offset) + 11(occupieds, runends, remainders) * 8 + 1 (remainders len)
-	if sz != 0x60 {
-		t.Errorf("got sz = 0x%X, want 0x60\n0x%X\n0x%X", sz, p0, p1)
+	p0 := uintptr(unsafe.Pointer(f.block(3)))
+	p1 := uintptr(unsafe.Pointer(f.block(4)))
+	r := f.remainders(3)
+	r0 := uintptr(unsafe.Pointer(&r[0]))
+	rn := uintptr(unsafe.Pointer(&r[len(r)-1]))
+	// 3(offset, occupieds, runends) + 9(remainders) * 8
+	if p1-p0 != 0x60 {
+		t.Errorf("got sz = 0x%X, want 0x60\n0x%X\n0x%X", p1-p0, p0, p1)
+	}
+
+	if r0-p0 != unsafe.Sizeof(block{}) || p1-rn != 8 {
+		t.Errorf("want remainders of block 3 between 0x%X and 0x%X, got 0x%X to 0x%X",
+			p0+unsafe.Sizeof(block{}), p1, r0, rn+8)
+	}
+}
+
+func Test_NewWithRate_should_size_remainders(t *testing.T) {
+	t.Parallel()
+	td := []struct {
+		n, rate       float64
+		p, r, q, qlen uint64
+	}{
+		{100000, 1.0 / 512.0, 26, 9, 17, 2105},
+		{100000, 1.0 / 65536.0, 33, 16, 17, 2105},
+		{100000, 1.0 / 64.0, 23, 6, 17, 2105},
+		{100000, 0.01, 24, 7, 17, 2105},
+		{1, 0.5, 7, 1, 6, 3},
+	}
+
+	for i, v := range td {
+		f, err := NewWithRate(v.n, v.rate)
+		if err != nil {
+			t.Fatalf("[%v] want NewWithRate(%v, %v) err = nil, got %v", i, v.n, v.rate, err)
+		}
+
+		if v.p != f.p || v.r != f.remainder || v.q != f.quotient {
+			t.Errorf("[%v] want p, r, q = %v, %v, %v, got %v, %v, %v",
+				i, v.p, v.r, v.q, f.p, f.remainder, f.quotient)
+		}
+
+		if v.qlen != f.blocks() || v.qlen*(blockMeta+v.r) != uint64(len(f.Q)) {
+			t.Errorf("[%v] want %v blocks and len(Q) = %v, got %v and %v",
+				i, v.qlen, v.qlen*(blockMeta+v.r), f.blocks(), len(f.Q))
+		}
+
+		if f.rMask != pow2(v.r)-1 || f.qMask != (pow2(v.p)-1)^f.rMask {
+			t.Errorf("[%v] got rMask = 0x%X, qMask = 0x%X", i, f.rMask, f.qMask)
+		}
+	}
+}
+
+func Test_NewWithRate_should_return_error_for_invalid_input(t *testing.T) {
+	t.Parallel()
+	td := []struct {
+		n, rate float64
+		err     error
+	}{
+		{100000, 0, ErrInvalidRate},
+		{100000, 1, ErrInvalidRate},
+		{100000, -0.5, ErrInvalidRate},
+		{0, 0.01, ErrInvalidSize},
+		{1e18, 1.0 / 65536.0, ErrInvalidSize},
+	}
+
+	for i, v := range td {
+		_, err := NewWithRate(v.n, v.rate)
+		if v.err != err {
+			t.Errorf("[%v] want NewWithRate(%v, %v) err = %v, got %v", i, v.n, v.rate, v.err, err)
+		}
 	}
 }
 
@@ -175,3 +291,189 @@ func Test_sample_p_values(t *testing.T) {
 		}
 	}
 }
+
+func Test_lastEnd(t *testing.T) {
+	t.Parallel()
+	f := New(100000)
+	// runs: home 62 [62, 64], home 63 [65], home 64 [66, 67], home 66 [68]
+	f.block(0).Occupieds = 0xC000000000000000
+	f.block(1).Occupieds = 0x05
+	f.block(1).Runends = 0x1B
+	f.block(1).Offset = 3
+
+	td := []struct {
+		x, end uint64
+		ok     bool
+	}{
+		{0, 0, false},
+		{62, 64, true},
+		{63, 65, true},
+		{64, 67, true},
+		{65, 67, true},
+		{66, 68, true},
+		{68, 68, true},
+		{69, 68, false},
+	}
+
+	for i, v := range td {
+		end, ok := f.lastEnd(v.x)
+		if v.ok != ok {
+			t.Errorf("[%v] want lastEnd(%v) ok = %v, got %v", i, v.x, v.ok, ok)
+		}
+		if ok && v.end != end {
+			t.Errorf("[%v] want lastEnd(%v) = %v, got %v", i, v.x, v.end, end)
+		}
+	}
+}
+
+// naiveOffset calculates Oi for block bi by ranking and selecting from the
+// start of Q.
+func naiveOffset(f *Rsqf, bi uint64) uint64 {
+	i := bi * blockLen
+	var r uint64
+	for s := uint64(0); s <= i; s++ {
+		if f.isOccupied(s) {
+			r++
+		}
+	}
+	if r == 0 {
+		return 0
+	}
+
+	for s := uint64(0); s < f.slots(); s++ {
+		if f.isRunend(s) {
+			r--
+			if r == 0 {
+				if s < i {
+					return 0
+				}
+				return s - i
+			}
+		}
+	}
+	return f.slots()
+}
+
+func Test_Insert_should_maintain_offsets(t *testing.T) {
+	t.Parallel()
+	f := New(10000)
+	r := rand.New(rand.NewSource(7))
+
+	for i := 0; i < 12000; i++ {
+		x := r.Uint64()
+		if err := f.Insert(x); err != nil {
+			t.Fatalf("[%v] want Insert(0x%X) err = nil, got %v", i, x, err)
+		}
+	}
+
+	for bi := uint64(0); bi < f.blocks(); bi++ {
+		expected := naiveOffset(f, bi)
+		if expected != f.block(bi).Offset {
+			t.Errorf("want block(%v).Offset = %v, got %v", bi, expected, f.block(bi).Offset)
+		}
+	}
+}
+
+func Test_offset_should_saturate_for_long_clusters(t *testing.T) {
+	t.Parallel()
+	f := New(100000)
+
+	// 700 remainders for the same home slot spans 11 blocks.
+	for i := uint64(0); i < 700; i++ {
+		x := 10<<9 | i&0x1FF
+		if err := f.Insert(x); err != nil {
+			t.Fatalf("[%v] want Insert(0x%X) err = nil, got %v", i, x, err)
+		}
+	}
+
+	for i := uint64(0); i < 5; i++ {
+		x := (70+i)<<9 | i
+		if err := f.Insert(x); err != nil {
+			t.Fatalf("[%v] want Insert(0x%X) err = nil, got %v", i, x, err)
+		}
+	}
+
+	for bi := uint64(0); bi < 14; bi++ {
+		expected := naiveOffset(f, bi)
+		if expected != f.offset(bi) {
+			t.Errorf("want offset(%v) = %v, got %v", bi, expected, f.offset(bi))
+		}
+
+		stored := expected
+		if stored > offsetMax {
+			stored = offsetMax
+		}
+		if stored != f.block(bi).Offset {
+			t.Errorf("want block(%v).Offset = %v, got %v", bi, stored, f.block(bi).Offset)
+		}
+	}
+
+	for i := uint64(0); i < 5; i++ {
+		x := (70+i)<<9 | i
+		if !f.MayContainHash(x) {
+			t.Errorf("[%v] want MayContainHash(0x%X) = true, got false", i, x)
+		}
+	}
+
+	if !f.MayContainHash(10<<9 | 0x1FF) {
+		t.Error("want MayContainHash(10<<9 | 0x1FF) = true, got false")
+	}
+}
+
+func Test_Insert_and_Delete_should_match_model(t *testing.T) {
+	t.Parallel()
+	f := New(10000)
+	r := rand.New(rand.NewSource(11))
+	pmask := f.qMask | f.rMask
+	model := map[uint64]int{}
+	var keys []uint64
+
+	for i := 0; i < 40000; i++ {
+		if len(keys) < 11000 && (len(keys) == 0 || r.Intn(3) > 0) {
+			// keys are limited to p bits so some fingerprints repeat.
+			x := r.Uint64() & 0x7FFFFF
+			if err := f.Insert(x); err != nil {
+				t.Fatalf("[%v] want Insert(0x%X) err = nil, got %v", i, x, err)
+			}
+			model[x&pmask]++
+			keys = append(keys, x)
+			continue
+		}
+
+		j := r.Intn(len(keys))
+		x := keys[j]
+		keys[j] = keys[len(keys)-1]
+		keys = keys[:len(keys)-1]
+
+		ok, err := f.Delete(x)
+		if !ok || err != nil {
+			t.Fatalf("[%v] want Delete(0x%X) = true, nil, got %v, %v", i, x, ok, err)
+		}
+		model[x&pmask]--
+	}
+
+	for bi := uint64(0); bi < f.blocks(); bi++ {
+		expected := naiveOffset(f, bi)
+		if expected != f.offset(bi) {
+			t.Errorf("want offset(%v) = %v, got %v", bi, expected, f.offset(bi))
+		}
+	}
+
+	for x, n := range model {
+		if (n > 0) != f.MayContainHash(x) {
+			t.Errorf("want MayContainHash(0x%X) = %v, got %v", x, n > 0, !(n > 0))
+		}
+	}
+
+	for _, x := range keys {
+		if ok, _ := f.Delete(x); !ok {
+			t.Errorf("want Delete(0x%X) = true, got false", x)
+		}
+	}
+
+	for bi := uint64(0); bi < f.blocks(); bi++ {
+		if b := f.block(bi); b.Occupieds != 0 || b.Runends != 0 || b.Offset != 0 {
+			t.Errorf("want block(%v) to be empty, got %+v", bi, b)
+		}
+	}
+}
@@ -10,6 +10,8 @@
 package rsqf_test
 
 import (
+	"fmt"
+	"math/rand"
 	"testing"
 
 	. "github.com/nfisher/rsqf"
@@ -21,17 +23,17 @@ func Test_Insert_simple_run(t *testing.T) {
 	f.Insert(0x01F0)
 	f.Insert(0x01FF)
 
-	if 0x02 != f.Q[0].Runends {
-		t.Errorf("want Q[0].Runends = 0x%X, got 0x%X", 0x02, f.Q[0].Runends)
+	if 0x02 != f.Block(0).Runends {
+		t.Errorf("want Block(0).Runends = 0x%X, got 0x%X", 0x02, f.Block(0).Runends)
 	}
 
-	if 0x01 != f.Q[0].Occupieds {
-		t.Errorf("want Q[0].Occupieds = 0x%X, got 0x%X",
-			0x01, f.Q[0].Occupieds)
+	if 0x01 != f.Block(0).Occupieds {
+		t.Errorf("want Block(0).Occupieds = 0x%X, got 0x%X",
+			0x01, f.Block(0).Occupieds)
 	}
 
-	if 0x3FFF0 != f.Q[0].Remainders[0] {
-		t.Errorf("want Q[0].Remainders[0] = 0x3FFF0, got 0x%X", f.Q[0].Remainders[0])
+	if 0x3FFF0 != f.Remainders(0)[0] {
+		t.Errorf("want Remainders(0)[0] = 0x3FFF0, got 0x%X", f.Remainders(0)[0])
 	}
 }
 
@@ -57,20 +59,20 @@ func Test_Insert_simple(t *testing.T) {
 		f := New(100000)
 		f.Insert(h)
 
-		if re != f.Q[0].Runends {
-			t.Errorf("[%v] want Q[0].Runends = 0x%X, got 0x%X",
-				i, re, f.Q[0].Runends)
+		if re != f.Block(0).Runends {
+			t.Errorf("[%v] want Block(0).Runends = 0x%X, got 0x%X",
+				i, re, f.Block(0).Runends)
 		}
 
-		if o != f.Q[0].Occupieds {
-			t.Errorf("[%v] want Q[0].Occupieds = 0x%X, got 0x%X",
-				i, o, f.Q[0].Occupieds)
+		if o != f.Block(0).Occupieds {
+			t.Errorf("[%v] want Block(0).Occupieds = 0x%X, got 0x%X",
+				i, o, f.Block(0).Occupieds)
 		}
 
 		r0 := v[3]
-		if r0 != f.Q[0].Remainders[0] {
-			t.Errorf("[%v] want Q[0].Remainders = 0x%X, got 0x%X",
-				i, r0, f.Q[0].Remainders[0])
+		if r0 != f.Remainders(0)[0] {
+			t.Errorf("[%v] want Remainders(0)[0] = 0x%X, got 0x%X",
+				i, r0, f.Remainders(0)[0])
 		}
 	}
 }
@@ -81,7 +83,7 @@ func Test_Put2_run_element(t *testing.T) {
 	f.Put2(0x00, 0x1F0)
 	f.Put2(0x01, 0x10F)
 
-	Q := f.Q[0]
+	Q := f.Remainders(0)
 
 	td := []uint64{
 		0x0000000000000002, 0x0000000000000002, 0x0000000000000002, 0x0000000000000002,
@@ -91,8 +93,8 @@ func Test_Put2_run_element(t *testing.T) {
 
 	for i, v := range td {
 		remainders := v
-		if remainders != Q.Remainders[i] {
-			t.Errorf("want Q[0].Remainders[%v] = 0x%X, got 0x%X", i, remainders, Q.Remainders[i])
+		if remainders != Q[i] {
+			t.Errorf("want Remainders(0)[%v] = 0x%X, got 0x%X", i, remainders, Q[i])
 		}
 	}
 }
@@ -101,9 +103,9 @@ func Test_Put2_in_same_block_without_run(t *testing.T) {
 	t.Parallel()
 	td := [][]uint64{
 		// h0,   h1,        Q.occupieds,
-		// Q[0].Remainders[0], Q[0].Remainders[1], Q[0].Remainders[2],
-		// Q[0].Remainders[3], Q[0].Remainders[4], Q[0].Remainders[5],
-		// Q[0].Remainders[6], Q[0].Remainders[7], Q[0].Remainders[8]
+		// Remainders(b)[0], Remainders(b)[1], Remainders(b)[2],
+		// Remainders(b)[3], Remainders(b)[4], Remainders(b)[5],
+		// Remainders(b)[6], Remainders(b)[7], Remainders(b)[8]
 		// b
 
 		// 0 - 1st block, first rank, partial r bits on
@@ -136,13 +138,13 @@ func Test_Put2_in_same_block_without_run(t *testing.T) {
 		f := New(100000)
 		f.Put2(v[0], v[1])
 		b := v[12]
-		Q := f.Q[b]
+		Q := f.Remainders(b)
 
 		for j := 0; j < 9; j++ {
 			remainders := v[3+j]
-			if remainders != Q.Remainders[j] {
-				t.Errorf("[%v] want Q[%v].Remainders[%v] = 0x%X, got 0x%X",
-					i, b, j, remainders, Q.Remainders[j])
+			if remainders != Q[j] {
+				t.Errorf("[%v] want Remainders(%v)[%v] = 0x%X, got 0x%X",
+					i, b, j, remainders, Q[j])
 			}
 		}
 	}
@@ -152,9 +154,9 @@ func Test_Put_in_same_block_without_run(t *testing.T) {
 	t.Parallel()
 	td := [][]uint64{
 		// h0,   h1,        Q.occupieds,
-		// Q[0].Remainders[0], Q[0].Remainders[1], Q[0].Remainders[2],
-		// Q[0].Remainders[3], Q[0].Remainders[4], Q[0].Remainders[5],
-		// Q[0].Remainders[6], Q[0].Remainders[7], Q[0].Remainders[8]
+		// Remainders(b)[0], Remainders(b)[1], Remainders(b)[2],
+		// Remainders(b)[3], Remainders(b)[4], Remainders(b)[5],
+		// Remainders(b)[6], Remainders(b)[7], Remainders(b)[8]
 		// b
 
 		// 0 - span 1st and 2nd r-bit cell
@@ -235,14 +237,387 @@ func Test_Put_in_same_block_without_run(t *testing.T) {
 		f := New(100000)
 		f.Put(v[0], v[1])
 		b := v[12]
-		Q := f.Q[b]
+		Q := f.Remainders(b)
 
 		for j := 0; j < 9; j++ {
 			remainders := v[3+j]
-			if remainders != Q.Remainders[j] {
-				t.Errorf("[%v] want Q[%v].Remainders[%v] = 0x%X, got 0x%X",
-					i, b, j, remainders, Q.Remainders[j])
+			if remainders != Q[j] {
+				t.Errorf("[%v] want Remainders(%v)[%v] = 0x%X, got 0x%X",
+					i, b, j, remainders, Q[j])
 			}
 		}
 	}
 }
+
+// clusterAcrossBlocks builds a cluster by hand that starts in block 0 and
+// spills into block 1;
+//
+//	slot:  62   63   64   65   66   67   68
+//	home:  62   62   62   63   64   64   66
+//	rem:   0x1A 0x1B 0x1C 0x1D 0x1E 0x1F 0x20
+func clusterAcrossBlocks() *Rsqf {
+	f := New(100000)
+	f.Block(0).Occupieds = 0xC000000000000000
+	f.Block(1).Occupieds = 0x05
+	f.Block(1).Runends = 0x1B
+	f.Block(1).Offset = 3
+
+	var rem uint64 = 0x1A
+	for slot := uint64(62); slot <= 68; slot++ {
+		f.Put(slot, rem)
+		rem++
+	}
+	return f
+}
+
+func Test_MayContainHash_across_blocks(t *testing.T) {
+	t.Parallel()
+	f := clusterAcrossBlocks()
+
+	td := []struct {
+		h0, h1   uint64
+		expected bool
+	}{
+		{62, 0x1A, true},
+		{62, 0x1B, true},
+		{62, 0x1C, true},
+		{62, 0x1D, false},
+		{63, 0x1D, true},
+		{63, 0x1C, false},
+		{64, 0x1E, true},
+		{64, 0x1F, true},
+		{64, 0x1D, false},
+		{65, 0x1F, false},
+		{66, 0x20, true},
+		{66, 0x1F, false},
+		{0, 0x00, false},
+	}
+
+	for i, v := range td {
+		x := v.h0<<9 | v.h1
+		actual := f.MayContainHash(x)
+		if v.expected != actual {
+			t.Errorf("[%v] want MayContainHash(0x%X) = %v, got %v", i, x, v.expected, actual)
+		}
+	}
+}
+
+func Test_MayContain_empty_filter(t *testing.T) {
+	t.Parallel()
+	f := New(100000)
+	if f.MayContain([]byte("Hello world")) {
+		t.Error("want MayContain() = false for an empty filter, got true")
+	}
+}
+
+func Test_Get_should_return_Put_value(t *testing.T) {
+	t.Parallel()
+	f := New(100000)
+	td := []uint64{0x00, 0x07, 0x0E, 0x3F, 0x40, 0x1FFFF}
+
+	for i, h0 := range td {
+		f.Put(h0, 0x1A5)
+		if 0x1A5 != f.Get(h0) {
+			t.Errorf("[%v] want Get(0x%X) = 0x1A5, got 0x%X", i, h0, f.Get(h0))
+		}
+	}
+}
+
+func Test_Insert_should_shift_following_runs(t *testing.T) {
+	t.Parallel()
+	f := New(100000)
+	// h0 = 1, then two remainders for h0 = 0 push it right.
+	f.Insert(0x0205)
+	f.Insert(0x0003)
+	f.Insert(0x0001)
+
+	if 0x06 != f.Block(0).Runends {
+		t.Errorf("want Block(0).Runends = 0x06, got 0x%X", f.Block(0).Runends)
+	}
+
+	if 0x03 != f.Block(0).Occupieds {
+		t.Errorf("want Block(0).Occupieds = 0x03, got 0x%X", f.Block(0).Occupieds)
+	}
+
+	td := []uint64{0x01, 0x03, 0x05}
+	for i, v := range td {
+		if v != f.Get(uint64(i)) {
+			t.Errorf("want Get(%v) = 0x%X, got 0x%X", i, v, f.Get(uint64(i)))
+		}
+	}
+}
+
+func Test_Insert_long_cluster_across_blocks(t *testing.T) {
+	t.Parallel()
+	f := New(100000)
+
+	var inserted []uint64
+	for h0 := uint64(60); h0 < 70; h0++ {
+		for h1 := uint64(0); h1 < 15; h1++ {
+			x := h0<<9 | (h1*31)&0x1FF
+			inserted = append(inserted, x)
+			err := f.Insert(x)
+			if err != nil {
+				t.Fatalf("want Insert(0x%X) err = nil, got %v", x, err)
+			}
+
+			for _, y := range inserted {
+				if !f.MayContainHash(y) {
+					t.Fatalf("want MayContainHash(0x%X) = true after Insert(0x%X), got false", y, x)
+				}
+			}
+		}
+	}
+
+	if f.MayContainHash(70<<9 | 0x01) {
+		t.Error("want MayContainHash(70<<9 | 0x01) = false, got true")
+	}
+
+	if f.MayContainHash(65<<9 | 0x01) {
+		t.Error("want MayContainHash(65<<9 | 0x01) = false, got true")
+	}
+}
+
+func Test_Insert_random_keys_should_be_found(t *testing.T) {
+	t.Parallel()
+	f := New(100000)
+	r := rand.New(rand.NewSource(42))
+
+	inserted := make([]uint64, 50000)
+	for i := range inserted {
+		inserted[i] = r.Uint64()
+		err := f.Insert(inserted[i])
+		if err != nil {
+			t.Fatalf("[%v] want Insert(0x%X) err = nil, got %v", i, inserted[i], err)
+		}
+	}
+
+	for i, x := range inserted {
+		if !f.MayContainHash(x) {
+			t.Errorf("[%v] want MayContainHash(0x%X) = true, got false", i, x)
+		}
+	}
+}
+
+func Test_Insert_should_use_overflow_slots_after_the_last_home_slot(t *testing.T) {
+	t.Parallel()
+	f := New(1000)
+	td := []uint64{0x3FF<<9 | 0x02, 0x3FF<<9 | 0x01, 0x3FE << 9}
+
+	for i, x := range td {
+		err := f.Insert(x)
+		if err != nil {
+			t.Errorf("[%v] want Insert(0x%X) err = nil, got %v", i, x, err)
+		}
+	}
+
+	for i, x := range td {
+		if !f.MayContainHash(x) {
+			t.Errorf("[%v] want MayContainHash(0x%X) = true, got false", i, x)
+		}
+	}
+
+	err := f.Verify()
+	if err != nil {
+		t.Errorf("want Verify() = nil, got %v", err)
+	}
+}
+
+func Test_Insert_should_return_error_on_overflow(t *testing.T) {
+	t.Parallel()
+	// 1024 home slots followed by 320 overflow slots.
+	f := New(1000)
+	for i := 0; i < 321; i++ {
+		err := f.Insert(0x3FF << 9)
+		if err != nil {
+			t.Fatalf("[%v] want Insert(0x3FF << 9) err = nil, got %v", i, err)
+		}
+	}
+
+	err := f.Insert(0x3FF << 9)
+	if err != ErrFilterOverflow {
+		t.Errorf("want Insert(0x3FF << 9) err = ErrFilterOverflow, got %v", err)
+	}
+}
+
+func Test_Insert_should_fit_n_random_hashes(t *testing.T) {
+	t.Parallel()
+	td := []float64{1000, 100000}
+
+	for _, n := range td {
+		for seed := int64(0); seed < 10; seed++ {
+			f := New(n)
+			r := rand.New(rand.NewSource(seed))
+			for i := 0; i < int(n); i++ {
+				x := r.Uint64()
+				err := f.Insert(x)
+				if err != nil {
+					t.Fatalf("[%v, %v] want Insert(0x%X) err = nil after %v inserts, got %v", n, seed, x, i, err)
+				}
+			}
+
+			err := f.Verify()
+			if err != nil {
+				t.Errorf("[%v, %v] want Verify() = nil, got %v", n, seed, err)
+			}
+		}
+	}
+}
+
+func Test_Delete_should_remove_one_instance(t *testing.T) {
+	t.Parallel()
+	f := New(100000)
+	f.Insert(0x01F0)
+	f.Insert(0x01F0)
+
+	td := []bool{true, true, false}
+	for i, expected := range td {
+		ok, err := f.Delete(0x01F0)
+		if err != nil {
+			t.Errorf("[%v] want Delete(0x01F0) err = nil, got %v", i, err)
+		}
+
+		if expected != ok {
+			t.Errorf("[%v] want Delete(0x01F0) = %v, got %v", i, expected, ok)
+		}
+	}
+
+	if f.MayContainHash(0x01F0) {
+		t.Error("want MayContainHash(0x01F0) = false, got true")
+	}
+
+	if 0 != f.Block(0).Occupieds || 0 != f.Block(0).Runends || 0 != f.Remainders(0)[0] {
+		t.Errorf("want block 0 to be empty, got %+v", f.Block(0))
+	}
+}
+
+func Test_Delete_should_shift_displaced_runs_left(t *testing.T) {
+	t.Parallel()
+	f := New(100000)
+	// slots: 0:(0, 0x01) 1:(0, 0x03) 2:(1, 0x05) 3:(3, 0x07)
+	f.Insert(0x0205)
+	f.Insert(0x0003)
+	f.Insert(0x0001)
+	f.Insert(0x0607)
+
+	ok, _ := f.Delete(0x0001)
+	if !ok {
+		t.Fatal("want Delete(0x0001) = true, got false")
+	}
+
+	if 0x0B != f.Block(0).Runends {
+		t.Errorf("want Block(0).Runends = 0x0B, got 0x%X", f.Block(0).Runends)
+	}
+
+	td := []uint64{0x03, 0x05, 0x00, 0x07}
+	for i, v := range td {
+		if v != f.Get(uint64(i)) {
+			t.Errorf("want Get(%v) = 0x%X, got 0x%X", i, v, f.Get(uint64(i)))
+		}
+	}
+
+	for _, x := range []uint64{0x0003, 0x0205, 0x0607} {
+		if !f.MayContainHash(x) {
+			t.Errorf("want MayContainHash(0x%X) = true, got false", x)
+		}
+	}
+}
+
+func Test_Delete_missing_remainder(t *testing.T) {
+	t.Parallel()
+	f := New(100000)
+	f.Insert(0x0003)
+
+	td := []uint64{0x0002, 0x0004, 0x0203}
+	for i, x := range td {
+		ok, err := f.Delete(x)
+		if ok || err != nil {
+			t.Errorf("[%v] want Delete(0x%X) = false, nil, got %v, %v", i, x, ok, err)
+		}
+	}
+}
+
+func Test_NewWithRate_filters_should_find_inserted_keys(t *testing.T) {
+	t.Parallel()
+	td := []float64{0.5, 1.0 / 64.0, 0.01, 1.0 / 8192.0, 1.0 / 65536.0, 1.0 / (1 << 40)}
+
+	for i, rate := range td {
+		f, err := NewWithRate(10000, rate)
+		if err != nil {
+			t.Fatalf("[%v] want NewWithRate(10000, %v) err = nil, got %v", i, rate, err)
+		}
+
+		r := rand.New(rand.NewSource(int64(i)))
+		inserted := make([]uint64, 5000)
+		for j := range inserted {
+			inserted[j] = r.Uint64()
+			err := f.Insert(inserted[j])
+			if err != nil {
+				t.Fatalf("[%v] want Insert(0x%X) err = nil, got %v", i, inserted[j], err)
+			}
+		}
+
+		for j, x := range inserted {
+			if !f.MayContainHash(x) {
+				t.Fatalf("[%v] want MayContainHash(0x%X) = true for inserted[%v], got false", i, x, j)
+			}
+		}
+
+		for j, x := range inserted {
+			ok, err := f.Delete(x)
+			if !ok || err != nil {
+				t.Fatalf("[%v] want Delete(0x%X) = true, nil for inserted[%v], got %v, %v", i, x, j, ok, err)
+			}
+		}
+
+		for j, v := range f.Q {
+			if v != 0 {
+				t.Fatalf("[%v] want Q[%v] = 0 after deleting all keys, got 0x%X", i, j, v)
+			}
+		}
+	}
+}
+
+func Test_InsertString_should_be_found(t *testing.T) {
+	t.Parallel()
+	f := New(100000)
+	td := []string{"Hello world", "hello world", "", "rank", "select"}
+
+	for i, v := range td[:3] {
+		err := f.InsertString(v)
+		if err != nil {
+			t.Errorf("[%v] want InsertString(%q) err = nil, got %v", i, v, err)
+		}
+	}
+
+	for i, v := range td {
+		expected := i < 3
+		if expected != f.MayContainString(v) {
+			t.Errorf("[%v] want MayContainString(%q) = %v, got %v", i, v, expected, !expected)
+		}
+
+		if expected != f.MayContain([]byte(v)) {
+			t.Errorf("[%v] want MayContain(%q) = %v, got %v", i, v, expected, !expected)
+		}
+	}
+}
+
+func Test_InsertBytes_should_not_collide_for_distinct_keys(t *testing.T) {
+	t.Parallel()
+	f := New(100000)
+	for i := 0; i < 1000; i++ {
+		f.InsertBytes([]byte(fmt.Sprintf("key-%v", i)))
+	}
+
+	var fp int
+	for i := 0; i < 10000; i++ {
+		if f.MayContainString(fmt.Sprintf("missing-%v", i)) {
+			fp++
+		}
+	}
+
+	// 1000 entries in 2^26 fingerprints gives a rate well under 1/512.
+	if fp > 20 {
+		t.Errorf("want <= 20 false positives from 10000 lookups, got %v", fp)
+	}
+}
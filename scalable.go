@@ -0,0 +1,345 @@
+package rsqf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"math"
+)
+
+const (
+	// scalableGrowth is the factor by which each filter in a chain is larger
+	// than the one before.
+	scalableGrowth = 2
+
+	// scalableTightening is the factor applied to the error rate of each
+	// filter in a chain. The rates form a geometric series so their sum, the
+	// compound false-positive rate, is bounded by the target.
+	scalableTightening = 0.5
+)
+
+// ErrScalableOrdered is returned when a ScalableRsqf is configured with an
+// ordered hasher. The filters in a chain have different universes so a single
+// order-preserving hash can't serve them all.
+var ErrScalableOrdered = errors.New("RSQF scalable filters can't use an ordered hasher")
+
+/*
+ScalableRsqf is a chain of filters that keeps accepting inserts after the
+universe p of a single filter is exhausted. Each filter has scalableGrowth
+times the capacity of the one before and an error rate scalableTightening
+times smaller;
+
+	n(i) = N * 2^i
+	δ(i) = δ * (1 - 1/2) * (1/2)^i
+
+The false-positive rate of the chain is at most the sum of δ(i) which is
+less than δ. Inserts go to the newest filter, a new one is started when it
+reaches its capacity or overflows. Lookups query every filter.
+*/
+type ScalableRsqf struct {
+	n        float64 // capacity of the first filter.
+	rate     float64 // target compound false-positive rate.
+	counting bool
+	hasher   Hasher
+	filters  []*Rsqf
+}
+
+// NewScalable returns a ScalableRsqf whose first filter holds c.N insertions
+// and whose compound false-positive rate is less than c.ErrRate. GrowAt is
+// ignored, the chain grows by adding filters.
+func NewScalable(c Config) (*ScalableRsqf, error) {
+	if c.Ordered || c.Hasher != nil && c.Hasher.ID() == Ordered {
+		return nil, ErrScalableOrdered
+	}
+
+	rate := c.ErrRate
+	if rate == 0 {
+		rate = errRate
+	}
+
+	s := &ScalableRsqf{
+		n:        c.N,
+		rate:     rate,
+		counting: c.Counting,
+		hasher:   c.Hasher,
+	}
+
+	_, err := s.add()
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// capacity returns the number of insertions filter i is sized for.
+func (s *ScalableRsqf) capacity(i int) float64 {
+	return s.n * math.Pow(scalableGrowth, float64(i))
+}
+
+// add starts a new filter at the end of the chain.
+func (s *ScalableRsqf) add() (*Rsqf, error) {
+	i := len(s.filters)
+	rate := s.rate * (1 - scalableTightening) * math.Pow(scalableTightening, float64(i))
+
+	f, err := NewWithConfig(Config{
+		N:        s.capacity(i),
+		ErrRate:  rate,
+		Counting: s.counting,
+		Hasher:   s.hasher,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.filters = append(s.filters, f)
+	return f, nil
+}
+
+// Members returns the number of filters in the chain.
+func (s *ScalableRsqf) Members() int {
+	return len(s.filters)
+}
+
+// Items returns the number of instances stored in the chain.
+func (s *ScalableRsqf) Items() uint64 {
+	var items uint64
+	for _, f := range s.filters {
+		items += f.items
+	}
+	return items
+}
+
+// Hash applies the chain's hashing algorithm to b.
+func (s *ScalableRsqf) Hash(b []byte) uint64 {
+	return s.filters[0].Hash(b)
+}
+
+// Insert places the hash x into the newest filter.
+func (s *ScalableRsqf) Insert(x uint64) error {
+	return s.InsertN(x, 1)
+}
+
+// InsertN adds n instances of the hash x to the newest filter. A new filter
+// is started if the newest is at capacity or x doesn't fit.
+func (s *ScalableRsqf) InsertN(x, n uint64) error {
+	if n == 0 {
+		return nil
+	}
+	return s.filters[0].perInstance(x, n, s.insert)
+}
+
+// insert is InsertN for a single instance or counter.
+func (s *ScalableRsqf) insert(x, n uint64) error {
+	i := len(s.filters) - 1
+	f := s.filters[i]
+	if float64(f.used) >= s.capacity(i) {
+		var err error
+		f, err = s.add()
+		if err != nil {
+			return err
+		}
+	}
+
+	err := f.InsertN(x, n)
+	if err != ErrFilterOverflow {
+		return err
+	}
+
+	f, err = s.add()
+	if err != nil {
+		return err
+	}
+	return f.InsertN(x, n)
+}
+
+// InsertBytes hashes b and inserts the result into the chain.
+func (s *ScalableRsqf) InsertBytes(b []byte) error {
+	return s.Insert(s.Hash(b))
+}
+
+// InsertString hashes str and inserts the result into the chain.
+func (s *ScalableRsqf) InsertString(str string) error {
+	return s.InsertBytes([]byte(str))
+}
+
+// Delete removes one instance of the hash x from the newest filter that
+// contains it. It returns false if x was not found.
+func (s *ScalableRsqf) Delete(x uint64) (bool, error) {
+	for i := len(s.filters) - 1; i >= 0; i-- {
+		ok, err := s.filters[i].Delete(x)
+		if ok || err != nil {
+			return ok, err
+		}
+	}
+	return false, nil
+}
+
+// Count returns the number of instances of the hash x in the chain.
+func (s *ScalableRsqf) Count(x uint64) uint64 {
+	var c uint64
+	for _, f := range s.filters {
+		c += f.Count(x)
+	}
+	return c
+}
+
+// MayContainHash tests if the hash x exists in any filter of the chain. The
+// newest filter is checked first as it holds the most entries.
+func (s *ScalableRsqf) MayContainHash(x uint64) bool {
+	for i := len(s.filters) - 1; i >= 0; i-- {
+		if s.filters[i].MayContainHash(x) {
+			return true
+		}
+	}
+	return false
+}
+
+// MayContain tests if b exists in the chain.
+func (s *ScalableRsqf) MayContain(b []byte) bool {
+	return s.MayContainHash(s.Hash(b))
+}
+
+// MayContainString tests if str exists in the chain.
+func (s *ScalableRsqf) MayContainString(str string) bool {
+	return s.MayContain([]byte(str))
+}
+
+// Verify checks the invariants of every filter in the chain. See
+// Rsqf.Verify.
+func (s *ScalableRsqf) Verify() error {
+	for _, f := range s.filters {
+		err := f.Verify()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+A chain is encoded as a 32 byte little-endian header followed by each filter in
+the binary format of Rsqf.WriteTo;
+
+	offset  size  field
+	0       4     magic "RSQS"
+	4       2     format version
+	6       2     reserved
+	8       4     header CRC32C with this field zeroed
+	12      4     number of filters
+	16      8     capacity of the first filter (float64)
+	24      8     target error rate (float64)
+	32            filters
+*/
+const (
+	scalableMagic     = "RSQS"
+	scalableVersion   = 1
+	scalableHeaderLen = 32
+)
+
+// MarshalBinary encodes the chain into the binary format.
+func (s *ScalableRsqf) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	_, err := s.WriteTo(&buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary replaces the chain with the one encoded in data.
+func (s *ScalableRsqf) UnmarshalBinary(data []byte) error {
+	r := bytes.NewReader(data)
+	_, err := s.ReadFrom(r)
+	if err != nil {
+		return err
+	}
+
+	if r.Len() != 0 {
+		return ErrInvalidFormat
+	}
+	return nil
+}
+
+// WriteTo writes the chain to w as a single stream.
+func (s *ScalableRsqf) WriteTo(w io.Writer) (int64, error) {
+	var b [scalableHeaderLen]byte
+	copy(b[0:4], scalableMagic)
+	binary.LittleEndian.PutUint16(b[4:6], scalableVersion)
+	binary.LittleEndian.PutUint32(b[12:16], uint32(len(s.filters)))
+	binary.LittleEndian.PutUint64(b[16:24], math.Float64bits(s.n))
+	binary.LittleEndian.PutUint64(b[24:32], math.Float64bits(s.rate))
+	binary.LittleEndian.PutUint32(b[8:12], crc32.Checksum(b[:], castagnoli))
+
+	n, err := w.Write(b[:])
+	total := int64(n)
+	if err != nil {
+		return total, err
+	}
+
+	for _, f := range s.filters {
+		n, err := f.WriteTo(w)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// ReadFrom replaces the chain with one read from r. Every filter must share
+// the counting mode and hasher of the first.
+func (s *ScalableRsqf) ReadFrom(r io.Reader) (int64, error) {
+	var b [scalableHeaderLen]byte
+	n, err := io.ReadFull(r, b[:])
+	total := int64(n)
+	if err != nil {
+		return total, noEOF(err)
+	}
+
+	if string(b[0:4]) != scalableMagic {
+		return total, ErrInvalidFormat
+	}
+	if binary.LittleEndian.Uint16(b[4:6]) != scalableVersion {
+		return total, ErrUnsupportedVersion
+	}
+
+	crc := binary.LittleEndian.Uint32(b[8:12])
+	binary.LittleEndian.PutUint32(b[8:12], 0)
+	if crc32.Checksum(b[:], castagnoli) != crc {
+		return total, ErrChecksum
+	}
+
+	members := binary.LittleEndian.Uint32(b[12:16])
+	if members == 0 {
+		return total, ErrInvalidFormat
+	}
+
+	chain := &ScalableRsqf{
+		n:    math.Float64frombits(binary.LittleEndian.Uint64(b[16:24])),
+		rate: math.Float64frombits(binary.LittleEndian.Uint64(b[24:32])),
+	}
+
+	// filters are appended as they are read so a corrupt count can't force a
+	// large allocation.
+	for i := uint32(0); i < members; i++ {
+		f := &Rsqf{}
+		n, err := f.ReadFrom(r)
+		total += n
+		if err != nil {
+			return total, err
+		}
+
+		if i == 0 {
+			chain.counting = f.counting
+			chain.hasher = f.hasher
+		} else if f.counting != chain.counting || !sameHasher(f.hasher, chain.hasher) {
+			return total, ErrInvalidFormat
+		}
+		chain.filters = append(chain.filters, f)
+	}
+
+	*s = *chain
+	return total, nil
+}
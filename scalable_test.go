@@ -0,0 +1,184 @@
+package rsqf_test
+
+import (
+	"io"
+	"math/rand"
+	"testing"
+
+	. "github.com/nfisher/rsqf"
+)
+
+func scalableFilter(t *testing.T, counting bool, keys []uint64) *ScalableRsqf {
+	s, err := NewScalable(Config{N: 1000, ErrRate: 0.01, Counting: counting})
+	if err != nil {
+		t.Fatalf("want NewScalable() err = nil, got %v", err)
+	}
+
+	for i, x := range keys {
+		err := s.Insert(x)
+		if err != nil {
+			t.Fatalf("[%v] want Insert(0x%X) err = nil, got %v", i, x, err)
+		}
+	}
+	return s
+}
+
+func randomKeys(seed int64, n int) []uint64 {
+	r := rand.New(rand.NewSource(seed))
+	keys := make([]uint64, n)
+	for i := range keys {
+		keys[i] = r.Uint64()
+	}
+	return keys
+}
+
+func Test_NewScalable_should_reject_ordered_hasher(t *testing.T) {
+	t.Parallel()
+	_, err := NewScalable(Config{N: 1000, Hasher: NewOrdered(0)})
+	if err != ErrScalableOrdered {
+		t.Errorf("want err = ErrScalableOrdered, got %v", err)
+	}
+}
+
+func Test_ScalableRsqf_should_add_a_filter_at_capacity(t *testing.T) {
+	t.Parallel()
+	// the first filter has r = 8 and q = 10 so each key has its own home
+	// slot.
+	keys := make([]uint64, 1001)
+	for i := range keys {
+		keys[i] = uint64(i) << 8
+	}
+	s := scalableFilter(t, false, keys[:1000])
+
+	if s.Members() != 1 {
+		t.Errorf("want Members() = 1 at capacity, got %v", s.Members())
+	}
+
+	err := s.Insert(keys[1000])
+	if err != nil {
+		t.Fatalf("want Insert() err = nil, got %v", err)
+	}
+
+	if s.Members() != 2 {
+		t.Errorf("want Members() = 2 past capacity, got %v", s.Members())
+	}
+
+	for i, x := range keys {
+		if !s.MayContainHash(x) {
+			t.Fatalf("[%v] want MayContainHash(0x%X) = true, got false", i, x)
+		}
+	}
+}
+
+func Test_ScalableRsqf_should_add_a_filter_when_a_key_overflows(t *testing.T) {
+	t.Parallel()
+	// the last home slot of the first filter and its 320 overflow slots take
+	// 321 copies, the rest go to the second filter.
+	x := uint64(0x3FF<<8 | 0x11)
+	s := scalableFilter(t, false, nil)
+	err := s.InsertN(x, 400)
+	if err != nil {
+		t.Fatalf("want InsertN() err = nil, got %v", err)
+	}
+
+	if s.Members() != 2 || s.Count(x) != 400 || s.Items() != 400 {
+		t.Errorf("want Members() = 2 and Count() = Items() = 400, got %v, %v, %v", s.Members(), s.Count(x), s.Items())
+	}
+
+	err = s.Verify()
+	if err != nil {
+		t.Errorf("want Verify() = nil, got %v", err)
+	}
+
+	// instances are removed from the newest filter first.
+	for i := 0; i < 400; i++ {
+		ok, err := s.Delete(x)
+		if !ok || err != nil {
+			t.Fatalf("[%v] want Delete(0x%X) = true, nil, got %v, %v", i, x, ok, err)
+		}
+	}
+
+	if s.MayContainHash(x) || s.Items() != 0 {
+		t.Errorf("want MayContainHash() = false and Items() = 0, got true, %v", s.Items())
+	}
+}
+
+func Test_ScalableRsqf_should_roundtrip(t *testing.T) {
+	t.Parallel()
+	keys := randomKeys(37, 5000)
+	s := scalableFilter(t, true, keys)
+
+	b, err := s.MarshalBinary()
+	if err != nil {
+		t.Fatalf("want MarshalBinary() err = nil, got %v", err)
+	}
+
+	var g ScalableRsqf
+	err = g.UnmarshalBinary(b)
+	if err != nil {
+		t.Fatalf("want UnmarshalBinary() err = nil, got %v", err)
+	}
+
+	if g.Members() != s.Members() {
+		t.Errorf("want Members() = %v, got %v", s.Members(), g.Members())
+	}
+
+	if g.Items() != s.Items() {
+		t.Errorf("want Items() = %v, got %v", s.Items(), g.Items())
+	}
+
+	for i, x := range keys {
+		if g.Count(x) != s.Count(x) {
+			t.Fatalf("[%v] want Count(0x%X) = %v, got %v", i, x, s.Count(x), g.Count(x))
+		}
+	}
+
+	// the decoded chain keeps growing from where it left off.
+	more := randomKeys(39, 20000)
+	for i, x := range more {
+		err := g.Insert(x)
+		if err != nil {
+			t.Fatalf("[%v] want Insert(0x%X) err = nil, got %v", i, x, err)
+		}
+	}
+
+	if g.Members() <= s.Members() {
+		t.Errorf("want Members() > %v, got %v", s.Members(), g.Members())
+	}
+
+	err = g.Verify()
+	if err != nil {
+		t.Errorf("want Verify() = nil, got %v", err)
+	}
+}
+
+func Test_ScalableRsqf_should_reject_invalid_data(t *testing.T) {
+	t.Parallel()
+	s := scalableFilter(t, false, randomKeys(41, 100))
+	b, _ := s.MarshalBinary()
+
+	corrupt := append([]byte{}, b...)
+	corrupt[16] ^= 0xFF
+
+	badMagic := append([]byte{}, b...)
+	badMagic[0] = 'X'
+
+	td := []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{"magic", badMagic, ErrInvalidFormat},
+		{"header", corrupt, ErrChecksum},
+		{"truncated", b[:len(b)-1], io.ErrUnexpectedEOF},
+		{"trailing", append(append([]byte{}, b...), 0), ErrInvalidFormat},
+	}
+
+	for _, tc := range td {
+		var g ScalableRsqf
+		err := g.UnmarshalBinary(tc.data)
+		if err != tc.err {
+			t.Errorf("%v want err = %v, got %v", tc.name, tc.err, err)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package rsqf
+
+import "math/bits"
+
+const (
+	l8   uint64 = 0x0101010101010101 // 1 in the low bit of every byte.
+	msbs uint64 = 0x8080808080808080 // 1 in the high bit of every byte.
+)
+
+/*
+selectBroadword returns the index of the ith 1 in B or 64 if B has less than i
+1s. It uses the broadword select from Vigna without any tables or loops;
+
+ 1. count the 1s in each byte and sum them so byte k holds the number of 1s in
+    bytes 0 to k.
+ 2. compare all of the byte sums with i - 1 at once to find the byte holding
+    the ith 1.
+ 3. spread the bits of that byte one per byte, sum them again and compare with
+    the rank remaining in the byte to find the bit.
+
+References:
+
+	http://vigna.di.unimi.it/ftp/papers/Broadword.pdf
+*/
+func selectBroadword(B, i uint64) uint64 {
+	if i == 0 || i > uint64(bits.OnesCount64(B)) {
+		return 64
+	}
+	k := i - 1
+
+	s := B - ((B >> 1) & 0x5555555555555555)
+	s = (s & 0x3333333333333333) + ((s >> 2) & 0x3333333333333333)
+	s = (s + (s >> 4)) & 0x0F0F0F0F0F0F0F0F
+	sums := s * l8
+
+	// the high bit of a byte is set when its sum is <= k.
+	le := ((k*l8 | msbs) - sums) & msbs
+	place := uint64(bits.OnesCount64(le)) * 8
+	rank := k - (((sums << 8) >> place) & 0xFF)
+
+	b := (B >> place) & 0xFF
+	spread := (b * l8) & 0x8040201008040201
+	ones := ((spread + 0x7F7F7F7F7F7F7F7F) & msbs) >> 7
+	sums = ones * l8
+	le = ((rank*l8 | msbs) - sums) & msbs
+
+	return place + uint64(bits.OnesCount64(le))
+}
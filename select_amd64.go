@@ -0,0 +1,24 @@
+//go:build !rsqf_table
+// +build !rsqf_table
+
+package rsqf
+
+// selectPDEP deposits the single bit in bit onto the 1s of B and returns the
+// index of the trailing zeros of the result, 64 if the result is 0.
+func selectPDEP(B, bit uint64) uint64
+
+// Select returns the index of the ith 1 in B. If B has less than i 1s, or i is
+// 0, 64 is returned which spans into the next bit vector. Callers continue the
+// search in the next vector with i - Rank(B, 63).
+//
+// On CPUs with a fast PDEP this is the instruction sequence from the CQF
+// paper;
+//
+//	SELECT(B, i) = TZCNT(PDEP(2^(i-1), B))
+func Select(B, i uint64) uint64 {
+	if hasFastPDEP {
+		// shifts of 64 or more are 0 in Go so i = 0 and i > 64 return 64.
+		return selectPDEP(B, uint64(1)<<(i-1))
+	}
+	return selectBroadword(B, i)
+}
@@ -0,0 +1,13 @@
+//go:build !rsqf_table
+// +build !rsqf_table
+
+#include "textflag.h"
+
+// func selectPDEP(B, bit uint64) uint64
+TEXT ·selectPDEP(SB), NOSPLIT, $0-24
+	MOVQ B+0(FP), AX
+	MOVQ bit+8(FP), BX
+	PDEPQ AX, BX, CX
+	TZCNTQ CX, CX
+	MOVQ CX, ret+16(FP)
+	RET
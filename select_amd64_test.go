@@ -0,0 +1,22 @@
+//go:build !rsqf_table
+// +build !rsqf_table
+
+package rsqf
+
+import "testing"
+
+func Test_selectPDEP_should_match_naive(t *testing.T) {
+	t.Parallel()
+	_, ebx, _, _ := cpuid(7, 0)
+	if ebx&(1<<8) == 0 {
+		t.Skip("BMI2 is not supported")
+	}
+
+	for _, v := range selectInputs() {
+		expected := naiveSelect(v[0], v[1])
+		actual := selectPDEP(v[0], uint64(1)<<(v[1]-1))
+		if expected != actual {
+			t.Fatalf("want selectPDEP(0x%X, %v) = %v, got %v", v[0], v[1], expected, actual)
+		}
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !amd64 && !rsqf_table
+// +build !amd64,!rsqf_table
+
+package rsqf
+
+// Select returns the index of the ith 1 in B. If B has less than i 1s, or i is
+// 0, 64 is returned which spans into the next bit vector. Callers continue the
+// search in the next vector with i - Rank(B, 63).
+func Select(B, i uint64) uint64 {
+	return selectBroadword(B, i)
+}
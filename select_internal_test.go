@@ -0,0 +1,87 @@
+package rsqf
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// naiveSelect is the original bit at a time Select.
+func naiveSelect(B, i uint64) uint64 {
+	if i == 0 {
+		return 64
+	}
+
+	var c uint64
+	var j uint64
+	for ; j < 64; j++ {
+		c += (B >> j) & 0x1
+		if i == c {
+			return j
+		}
+	}
+	return 64
+}
+
+// selectInputs returns edge cases and random words of varying density with
+// every rank from 0 to 65.
+func selectInputs() [][2]uint64 {
+	words := []uint64{0x0, 0x1, 0x8000000000000000, 0xFFFFFFFFFFFFFFFF, 0x8080808080808080, 0x0101010101010101}
+	r := rand.New(rand.NewSource(17))
+	for i := 0; i < 500; i++ {
+		words = append(words, r.Uint64(), r.Uint64()&r.Uint64()&r.Uint64(), r.Uint64()|r.Uint64())
+	}
+
+	var in [][2]uint64
+	for _, B := range words {
+		for i := uint64(0); i <= 65; i++ {
+			in = append(in, [2]uint64{B, i})
+		}
+	}
+	return in
+}
+
+func Test_Select_implementations_should_match_naive(t *testing.T) {
+	t.Parallel()
+	impls := map[string]func(B, i uint64) uint64{
+		"Select":          Select,
+		"selectBroadword": selectBroadword,
+	}
+
+	for name, fn := range impls {
+		for _, v := range selectInputs() {
+			expected := naiveSelect(v[0], v[1])
+			actual := fn(v[0], v[1])
+			if expected != actual {
+				t.Fatalf("[%v] want select(0x%X, %v) = %v, got %v", name, v[0], v[1], expected, actual)
+			}
+		}
+	}
+}
+
+func Test_Select_64_should_continue_in_next_word(t *testing.T) {
+	t.Parallel()
+	r := rand.New(rand.NewSource(23))
+
+	for n := 0; n < 200; n++ {
+		words := [2]uint64{r.Uint64() & r.Uint64(), r.Uint64()}
+		for i := uint64(1); i <= Rank(words[0], 63)+Rank(words[1], 63); i++ {
+			// the ith 1 across both words one bit at a time.
+			var expected, c uint64
+			for j := uint64(0); j < 128; j++ {
+				c += (words[j/64] >> (j % 64)) & 0x1
+				if c == i {
+					expected = j
+					break
+				}
+			}
+
+			actual := Select(words[0], i)
+			if actual == 64 {
+				actual += Select(words[1], i-Rank(words[0], 63))
+			}
+			if expected != actual {
+				t.Fatalf("want select(0x%X 0x%X, %v) = %v, got %v", words[1], words[0], i, expected, actual)
+			}
+		}
+	}
+}
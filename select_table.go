@@ -0,0 +1,46 @@
+//go:build rsqf_table
+// +build rsqf_table
+
+package rsqf
+
+// selectByteTable holds the index of the (r+1)th 1 in byte b at b<<3 | r or 8
+// if the byte doesn't have that many 1s.
+var selectByteTable = func() [256 * 8]uint8 {
+	var t [256 * 8]uint8
+	for b := 0; b < 256; b++ {
+		var r uint
+		for j := uint(0); j < 8; j++ {
+			t[b<<3|int(j)] = 8
+		}
+		for j := uint(0); j < 8; j++ {
+			if (b>>j)&0x1 == 1 {
+				t[b<<3|int(r)] = uint8(j)
+				r++
+			}
+		}
+	}
+	return t
+}()
+
+// Select returns the index of the ith 1 in B. If B has less than i 1s, or i is
+// 0, 64 is returned which spans into the next bit vector. Callers continue the
+// search in the next vector with i - Rank(B, 63).
+//
+// This is the byte table version kept for comparison with the broadword and
+// PDEP versions, build with -tags rsqf_table to use it.
+func Select(B, i uint64) uint64 {
+	if i == 0 {
+		return 64
+	}
+
+	var place uint64
+	for ; place < 64; place += 8 {
+		b := (B >> place) & rankMask
+		c := rankByteTable[b]
+		if i <= c {
+			return place + uint64(selectByteTable[b<<3|(i-1)])
+		}
+		i -= c
+	}
+	return 64
+}
@@ -0,0 +1,82 @@
+package rsqf
+
+import "errors"
+
+// ErrCorrupt is returned by Verify when the filter's invariants don't hold.
+var ErrCorrupt = errors.New("RSQF filter is corrupt")
+
+// Verify walks every block and checks the invariants of the filter;
+//
+//  1. every run has a home slot in occupieds and a runend at or after it so
+//     each cluster has the same number of occupieds and runends.
+//  2. no run extends past the end of Q and only home slots are occupied.
+//  3. the stored offsets agree with those calculated from the bitmaps.
+//  4. remainders are in ascending order within each run.
+//  5. the item count matches the entries stored.
+func (q *Rsqf) Verify() error {
+	err := q.finishGrow()
+	if err != nil {
+		return err
+	}
+
+	if uint64(len(q.Q)) != blocksFor(q.quotient)*(blockMeta+q.remainder) {
+		return ErrCorrupt
+	}
+
+	// open is the number of runs that have started but not ended, a cluster
+	// ends when it returns to 0.
+	var open, prev uint64
+	for bi := uint64(0); bi < q.blocks(); bi++ {
+		blk := q.block(bi)
+
+		prev = q.calcOffset(bi, prev)
+		o := prev
+		if o > offsetMax {
+			o = offsetMax
+		}
+		if blk.Offset != o {
+			return ErrCorrupt
+		}
+
+		if bi >= q.homeSlots()/blockLen && blk.Occupieds != 0 {
+			return ErrCorrupt
+		}
+
+		if open == 0 && blk.Occupieds == 0 {
+			if blk.Runends != 0 {
+				return ErrCorrupt
+			}
+			continue
+		}
+
+		for j := uint64(0); j < blockLen; j++ {
+			open += (blk.Occupieds >> j) & 0x1
+			if (blk.Runends>>j)&0x1 == 1 {
+				if open == 0 {
+					return ErrCorrupt
+				}
+				open--
+			}
+		}
+	}
+
+	if open != 0 {
+		return ErrCorrupt
+	}
+
+	var items, last uint64
+	c := cursor{q: q}
+	for x, n, ok := c.next(); ok; x, n, ok = c.next() {
+		if items > 0 && (x < last || (q.counting && x == last)) {
+			return ErrCorrupt
+		}
+		items += n
+		last = x
+	}
+
+	if items != q.items {
+		return ErrCorrupt
+	}
+
+	return nil
+}
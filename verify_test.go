@@ -0,0 +1,112 @@
+package rsqf_test
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"math/rand"
+	"testing"
+
+	. "github.com/nfisher/rsqf"
+)
+
+func filledFilter(counting bool) *Rsqf {
+	f, _ := NewWithConfig(Config{N: 10000, Counting: counting})
+
+	r := rand.New(rand.NewSource(3))
+	for i := 0; i < 2000; i++ {
+		f.InsertN(r.Uint64(), uint64(i%3+1))
+	}
+	return f
+}
+
+func Test_Verify_valid_filters(t *testing.T) {
+	t.Parallel()
+	td := []*Rsqf{New(1000), NewCounting(1000), filledFilter(false), filledFilter(true)}
+
+	for i, f := range td {
+		err := f.Verify()
+		if err != nil {
+			t.Errorf("[%v] want Verify() = nil, got %v", i, err)
+		}
+	}
+}
+
+func Test_Verify_corrupt_filters(t *testing.T) {
+	t.Parallel()
+	td := []struct {
+		name    string
+		corrupt func(f *Rsqf)
+	}{
+		{"runend cleared", func(f *Rsqf) {
+			for bi := uint64(0); bi < f.Blocks(); bi++ {
+				if b := f.Block(bi); b.Runends != 0 {
+					b.Runends &= b.Runends - 1
+					return
+				}
+			}
+		}},
+		{"occupied cleared", func(f *Rsqf) {
+			for bi := uint64(0); bi < f.Blocks(); bi++ {
+				if b := f.Block(bi); b.Occupieds != 0 {
+					b.Occupieds &= b.Occupieds - 1
+					return
+				}
+			}
+		}},
+		{"run past end", func(f *Rsqf) {
+			f.Block(f.Blocks() - 1).Occupieds |= 1 << 63
+		}},
+		{"offset", func(f *Rsqf) {
+			f.Block(f.Blocks()/4).Offset++
+		}},
+		{"truncated", func(f *Rsqf) {
+			f.Q = f.Q[:len(f.Q)-1]
+		}},
+	}
+
+	for _, v := range td {
+		f := filledFilter(false)
+		v.corrupt(f)
+		err := f.Verify()
+		if err != ErrCorrupt {
+			t.Errorf("[%v] want Verify() = ErrCorrupt, got %v", v.name, err)
+		}
+	}
+}
+
+func Test_Verify_unsorted_run(t *testing.T) {
+	t.Parallel()
+	f := New(1000)
+	f.Insert(0x0001)
+	f.Insert(0x0003)
+	f.Insert(0x0205)
+
+	f.Put(0, 0x03)
+	f.Put(1, 0x01)
+	err := f.Verify()
+	if err != ErrCorrupt {
+		t.Errorf("want Verify() = ErrCorrupt, got %v", err)
+	}
+}
+
+func Test_Verify_item_count(t *testing.T) {
+	t.Parallel()
+	f := New(1000)
+	f.Insert(0x0001)
+	b, _ := f.MarshalBinary()
+
+	b[24] = 2
+	binary.LittleEndian.PutUint32(b[12:16], 0)
+	binary.LittleEndian.PutUint32(b[12:16], crc32.Checksum(b[:40], crc32.MakeTable(crc32.Castagnoli)))
+
+	var g Rsqf
+	err := g.UnmarshalBinary(b)
+	if err != nil {
+		t.Fatalf("want UnmarshalBinary() err = nil, got %v", err)
+	}
+
+	err = g.Verify()
+	if err != ErrCorrupt {
+		t.Errorf("want Verify() = ErrCorrupt, got %v", err)
+	}
+}
@@ -0,0 +1,92 @@
+package rsqf
+
+import "encoding/binary"
+
+const (
+	xxPrime1 uint64 = 11400714785074694791
+	xxPrime2 uint64 = 14029467366897019727
+	xxPrime3 uint64 = 1609587929392839161
+	xxPrime4 uint64 = 9650029242287828579
+	xxPrime5 uint64 = 2870177450012600261
+)
+
+type xxHasher struct {
+	seed uint64
+}
+
+// NewXXHash64 returns a 64-bit xxHash hasher.
+//
+// Reference: https://github.com/Cyan4973/xxHash/blob/dev/doc/xxhash_spec.md
+func NewXXHash64(seed uint64) Hasher {
+	return &xxHasher{seed: seed}
+}
+
+func (h *xxHasher) ID() HasherID {
+	return XXHash64
+}
+
+func (h *xxHasher) Seed() uint64 {
+	return h.seed
+}
+
+func xxRound(acc, input uint64) uint64 {
+	acc += input * xxPrime2
+	acc = rotl(acc, 31)
+	return acc * xxPrime1
+}
+
+func xxMergeRound(acc, val uint64) uint64 {
+	acc ^= xxRound(0, val)
+	return acc*xxPrime1 + xxPrime4
+}
+
+func (h *xxHasher) Sum64(b []byte) uint64 {
+	n := uint64(len(b))
+	var sum uint64
+
+	if len(b) >= 32 {
+		v1 := h.seed + xxPrime1 + xxPrime2
+		v2 := h.seed + xxPrime2
+		v3 := h.seed
+		v4 := h.seed - xxPrime1
+		for ; len(b) >= 32; b = b[32:] {
+			v1 = xxRound(v1, binary.LittleEndian.Uint64(b[0:8]))
+			v2 = xxRound(v2, binary.LittleEndian.Uint64(b[8:16]))
+			v3 = xxRound(v3, binary.LittleEndian.Uint64(b[16:24]))
+			v4 = xxRound(v4, binary.LittleEndian.Uint64(b[24:32]))
+		}
+		sum = rotl(v1, 1) + rotl(v2, 7) + rotl(v3, 12) + rotl(v4, 18)
+		sum = xxMergeRound(sum, v1)
+		sum = xxMergeRound(sum, v2)
+		sum = xxMergeRound(sum, v3)
+		sum = xxMergeRound(sum, v4)
+	} else {
+		sum = h.seed + xxPrime5
+	}
+
+	sum += n
+
+	for ; len(b) >= 8; b = b[8:] {
+		sum ^= xxRound(0, binary.LittleEndian.Uint64(b[:8]))
+		sum = rotl(sum, 27)*xxPrime1 + xxPrime4
+	}
+
+	if len(b) >= 4 {
+		sum ^= uint64(binary.LittleEndian.Uint32(b[:4])) * xxPrime1
+		sum = rotl(sum, 23)*xxPrime2 + xxPrime3
+		b = b[4:]
+	}
+
+	for _, c := range b {
+		sum ^= uint64(c) * xxPrime5
+		sum = rotl(sum, 11) * xxPrime1
+	}
+
+	sum ^= sum >> 33
+	sum *= xxPrime2
+	sum ^= sum >> 29
+	sum *= xxPrime3
+	sum ^= sum >> 32
+
+	return sum
+}